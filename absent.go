@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	containerLastSeenTimestampSecondsDesc = newContainerDesc(
+		"container_last_seen_timestamp_seconds",
+		"Unix timestamp the container was last listed by the docker daemon",
+	)
+)
+
+type absentEntry struct {
+	descs  *descSet
+	values []string
+	seen   time.Time
+}
+
+// absentContainers remembers the label values of the listed containers for
+// --containers.absent-grace. Containers that are gone keep being reported as
+// not running until the grace period since they were last listed passed, so
+// alerts on disappeared containers can fire instead of their series just
+// going stale. A container listed again with the same labels continues its
+// series.
+type absentContainers struct {
+	grace time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*absentEntry
+}
+
+func newAbsentContainers(grace time.Duration) *absentContainers {
+	return &absentContainers{
+		grace:   grace,
+		entries: map[string]*absentEntry{},
+	}
+}
+
+// seen records that the container with the labels l was listed at t.
+func (a *absentContainers) seen(l *containerLabels, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[strings.Join(l.values, "\xff")] = &absentEntry{descs: l.descs, values: l.values, seen: t}
+}
+
+// collect reports the containers not listed since listed and drops the ones
+// absent for longer than the grace period. Entries of other label names, i.e.
+// before the label map was reloaded, are dropped as well.
+func (a *absentContainers) collect(ch chan<- prometheus.Metric, descs *descSet, listed time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, entry := range a.entries {
+		if !entry.seen.Before(listed) {
+			continue
+		}
+		if entry.descs != descs || time.Since(entry.seen) > a.grace {
+			delete(a.entries, key)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(descs.get(containerRunningDesc), prometheus.GaugeValue, 0, entry.values...)
+		ch <- prometheus.MustNewConstMetric(descs.get(containerLastSeenTimestampSecondsDesc), prometheus.GaugeValue, float64(entry.seen.UnixNano())/1e9, entry.values...)
+	}
+}
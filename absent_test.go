@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestAbsentContainers(t *testing.T) {
+	web := types.Container{ID: "a1", Names: []string{"/web"}, State: "exited"}
+	job := types.Container{ID: "b2", Names: []string{"/job"}, State: "exited"}
+
+	tests := []struct {
+		name  string
+		grace time.Duration
+		// containers listed by each scrape
+		scrapes [][]types.Container
+		// reports of the job container in the last scrape
+		wantJob int
+	}{
+		{
+			name:    "listed",
+			grace:   time.Minute,
+			scrapes: [][]types.Container{{web, job}},
+			wantJob: 1,
+		},
+		{
+			name:    "disappeared",
+			grace:   time.Minute,
+			scrapes: [][]types.Container{{web, job}, {web}},
+			wantJob: 1,
+		},
+		{
+			name:    "reappeared",
+			grace:   time.Minute,
+			scrapes: [][]types.Container{{web, job}, {web}, {web, job}},
+			wantJob: 1,
+		},
+		{
+			name:    "grace period passed",
+			grace:   time.Nanosecond,
+			scrapes: [][]types.Container{{web, job}, {web}},
+			wantJob: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeDocker{}
+			cfg := testConfig()
+			cfg.containersAbsentGrace = tt.grace
+			c := newDockerCollector(cfg, cli)
+
+			var m metrics
+			for _, containers := range tt.scrapes {
+				cli.containers = containers
+				m = collectMetrics(t, c.Collect)
+			}
+
+			var jobs int
+			for _, metric := range m["dex_container_running"] {
+				if hasLabels(metric, []string{"container_name", "job"}) {
+					jobs++
+				}
+			}
+			if jobs != tt.wantJob {
+				t.Fatalf("job reported %d times, want %d", jobs, tt.wantJob)
+			}
+			if got, ok := m.get("dex_container_running", "container_name", "web"); !ok || got != 0 {
+				t.Errorf("dex_container_running of web = %v, %t, want 0", got, ok)
+			}
+			if jobs == 1 {
+				if _, ok := m.get("dex_container_last_seen_timestamp_seconds", "container_name", "job"); !ok {
+					t.Error("no last seen timestamp of job")
+				}
+			}
+		})
+	}
+}
+
+func TestAbsentContainersLastSeen(t *testing.T) {
+	cli := &fakeDocker{containers: []types.Container{{ID: "b2", Names: []string{"/job"}, State: "running"}}}
+	cfg := testConfig()
+	cfg.containersAbsentGrace = time.Minute
+	c := newDockerCollector(cfg, cli)
+
+	m := collectMetrics(t, c.Collect)
+	seen, ok := m.get("dex_container_last_seen_timestamp_seconds", "container_name", "job")
+	if !ok {
+		t.Fatal("no last seen timestamp of job")
+	}
+
+	// the absent container keeps the time it was last listed and is reported
+	// as not running
+	cli.containers = nil
+	m = collectMetrics(t, c.Collect)
+	if got, ok := m.get("dex_container_last_seen_timestamp_seconds", "container_name", "job"); !ok || got != seen {
+		t.Errorf("last seen of the absent job = %v, %t, want %v", got, ok, seen)
+	}
+	if got, ok := m.get("dex_container_running", "container_name", "job"); !ok || got != 0 {
+		t.Errorf("dex_container_running of the absent job = %v, %t, want 0", got, ok)
+	}
+}
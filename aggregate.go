@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	hostContainersDesc = newDesc(
+		"host_containers",
+		"Number of containers per state",
+		"state",
+	)
+	hostCPUUtilizationSecondsTotalDesc = newDesc(
+		"host_cpu_utilization_seconds_total",
+		"Cumulative CPU utilization in seconds of all running containers",
+	)
+	hostCPUUtilizationPercentDesc = newDesc(
+		"host_cpu_utilization_percent",
+		"CPU utilization in percent of all running containers, 100% per core",
+	)
+	hostMemoryUsageBytesDesc = newDesc(
+		"host_memory_usage_bytes",
+		"Total memory usage bytes of all running containers",
+	)
+	hostNetworkRxBytesTotalDesc = newDesc(
+		"host_network_rx_bytes_total",
+		"Network received bytes total of all running containers",
+	)
+	hostNetworkTxBytesTotalDesc = newDesc(
+		"host_network_tx_bytes_total",
+		"Network sent bytes total of all running containers",
+	)
+	hostBlockIOReadBytesTotalDesc = newDesc(
+		"host_block_io_read_bytes_total",
+		"Block I/O read bytes of all running containers",
+	)
+	hostBlockIOWriteBytesTotalDesc = newDesc(
+		"host_block_io_write_bytes_total",
+		"Block I/O write bytes of all running containers",
+	)
+	imageContainersDesc = newDesc(
+		"image_containers",
+		"Number of containers per image reference and state",
+		"image",
+		"state",
+	)
+
+	// all states of docker containers
+	containerStateNames = []string{"created", "running", "paused", "restarting", "removing", "exited", "dead"}
+)
+
+type imageState struct {
+	image string
+	state string
+}
+
+// hostAggregate sums up container metrics across all containers of a single
+// collection.
+type hostAggregate struct {
+	descs *descSet
+
+	mu sync.Mutex
+
+	states      map[string]int
+	images      map[imageState]int
+	cpuSeconds  float64
+	cpuPercent  float64
+	memoryUsage float64
+	rxBytes     float64
+	txBytes     float64
+	readBytes   float64
+	writeBytes  float64
+}
+
+func newHostAggregate(descs *descSet) *hostAggregate {
+	return &hostAggregate{
+		descs:  descs,
+		states: map[string]int{},
+		images: map[imageState]int{},
+	}
+}
+
+func (a *hostAggregate) addContainer(container types.Container) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.states[container.State]++
+	a.images[imageState{image: container.Image, state: container.State}]++
+}
+
+func (a *hostAggregate) addStats(containerStats *types.StatsJSON, hostCPUs uint32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.cpuSeconds += cpuSeconds(containerStats)
+	// containers without utilization yet, e.g. just started, count as idle
+	if utilization, ok := cpuUtilization(containerStats, hostCPUs); ok {
+		a.cpuPercent += utilization
+	}
+	if isWindowsStats(containerStats) {
+		a.memoryUsage += float64(containerStats.MemoryStats.PrivateWorkingSet)
+	} else {
+		a.memoryUsage += float64(subtractBytes(containerStats.MemoryStats.Usage, containerStats.MemoryStats.Stats["cache"]))
+	}
+
+	for _, network := range containerStats.Networks {
+		a.rxBytes += float64(network.RxBytes)
+		a.txBytes += float64(network.TxBytes)
+	}
+
+	for _, b := range containerStats.BlkioStats.IoServiceBytesRecursive {
+		if strings.EqualFold(b.Op, "read") {
+			a.readBytes += float64(b.Value)
+		}
+		if strings.EqualFold(b.Op, "write") {
+			a.writeBytes += float64(b.Value)
+		}
+	}
+}
+
+// collectStates reports the number of containers per state, in all modes.
+func (a *hostAggregate) collectStates(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, state := range containerStateNames {
+		ch <- prometheus.MustNewConstMetric(a.descs.get(hostContainersDesc), prometheus.GaugeValue, float64(a.states[state]), state)
+	}
+}
+
+// collect reports the sums of the container stats, with
+// --metrics.aggregate-only or --metrics.host-aggregates.
+func (a *hostAggregate) collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostCPUUtilizationSecondsTotalDesc), prometheus.CounterValue, a.cpuSeconds)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostCPUUtilizationPercentDesc), prometheus.GaugeValue, a.cpuPercent)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostMemoryUsageBytesDesc), prometheus.GaugeValue, a.memoryUsage)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostNetworkRxBytesTotalDesc), prometheus.CounterValue, a.rxBytes)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostNetworkTxBytesTotalDesc), prometheus.CounterValue, a.txBytes)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostBlockIOReadBytesTotalDesc), prometheus.CounterValue, a.readBytes)
+	ch <- prometheus.MustNewConstMetric(a.descs.get(hostBlockIOWriteBytesTotalDesc), prometheus.CounterValue, a.writeBytes)
+}
+
+// collectImages reports the number of containers per image and state.
+func (a *hostAggregate) collectImages(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for is, count := range a.images {
+		ch <- prometheus.MustNewConstMetric(a.descs.get(imageContainersDesc), prometheus.GaugeValue, float64(count), is.image, is.state)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type backoffEntry struct {
+	failures int
+	until    time.Time
+}
+
+// statsBackoff tracks consecutive stats failures per container ID. After
+// threshold failures in a row, stats of the container are skipped for the
+// backoff period and retried afterwards. A recreated container gets a new ID
+// and starts without failures.
+type statsBackoff struct {
+	threshold int
+	backoff   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*backoffEntry
+}
+
+func newStatsBackoff(threshold int, backoff time.Duration) *statsBackoff {
+	return &statsBackoff{
+		threshold: threshold,
+		backoff:   backoff,
+		entries:   map[string]*backoffEntry{},
+	}
+}
+
+// skip reports whether the stats call for the container should be skipped.
+func (b *statsBackoff) skip(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[id]
+	return ok && time.Now().Before(entry.until)
+}
+
+func (b *statsBackoff) failure(id string, cName string) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.entries[id]
+	if !ok {
+		entry = &backoffEntry{}
+		b.entries[id] = entry
+	}
+
+	entry.failures++
+	if entry.failures >= b.threshold {
+		entry.until = time.Now().Add(b.backoff)
+		log.Warnf("stats of container %s failed %d times in a row, skipping them for %v", cName, entry.failures, b.backoff)
+	}
+}
+
+func (b *statsBackoff) success(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.entries, id)
+}
+
+// prune drops the entries of all containers not in ids.
+func (b *statsBackoff) prune(ids map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id := range b.entries {
+		if !ids[id] {
+			delete(b.entries, id)
+		}
+	}
+}
+
+// daemonBackoff skips the calls of scrapes while the docker daemon can't be
+// connected to, e.g. while it restarts. The delay between attempts doubles
+// from connectBackoffMin up to connectBackoffMax, so the failure is logged
+// once per attempt instead of on every scrape.
+type daemonBackoff struct {
+	mu       sync.Mutex
+	failures int
+	delay    time.Duration
+	until    time.Time
+}
+
+// skip reports whether the scrape should not contact the daemon.
+func (b *daemonBackoff) skip() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return time.Now().Before(b.until)
+}
+
+func (b *daemonBackoff) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.delay == 0 {
+		b.delay = connectBackoffMin
+	} else {
+		b.delay = min(2*b.delay, connectBackoffMax)
+	}
+	b.until = time.Now().Add(b.delay)
+
+	log.Errorf("can't connect to the docker daemon, retrying in %v: %v", b.delay, err)
+}
+
+func (b *daemonBackoff) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures > 0 {
+		log.Infof("docker daemon reachable again after %d failed attempts", b.failures)
+	}
+	b.failures = 0
+	b.delay = 0
+	b.until = time.Time{}
+}
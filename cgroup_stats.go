@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/system"
+	log "github.com/sirupsen/logrus"
+)
+
+// clock ticks per second of /proc/stat (USER_HZ, 100 on all relevant platforms)
+const clockTicks = 100
+
+// cgroupReader reads container stats directly from the cgroup filesystem
+// instead of the daemon's stats endpoint. Only the stats are read from there,
+// containers are still discovered and inspected through the API.
+type cgroupReader struct {
+	root   string
+	v2     bool
+	driver string
+
+	// previous CPU sample per container, the API's precpu_stats
+	samples *cpuSamples
+}
+
+// newCgroupReader checks that the cgroup filesystem is visible at root and
+// detects its version. driver is the daemon's cgroup driver (cgroupfs or systemd).
+func newCgroupReader(root, driver string) (*cgroupReader, error) {
+	r := &cgroupReader{
+		root:    root,
+		driver:  driver,
+		samples: newCPUSamples(),
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		r.v2 = true
+	} else if _, err := os.Stat(filepath.Join(root, "memory")); err != nil {
+		return nil, fmt.Errorf("no cgroup filesystem at %s", root)
+	}
+
+	return r, nil
+}
+
+// lazyCgroupReader sets up a cgroupReader once the daemon info, and with it
+// the cgroup driver, is available. dex may start before the daemon, the reader
+// is then created by the first scrape that gets the info.
+type lazyCgroupReader struct {
+	root string
+	// logged if the cgroup filesystem can't be used
+	disabled string
+	// cgroup v1 hosts can't be used either, e.g. for pressure stall information
+	needsV2 bool
+
+	mu     sync.Mutex
+	reader *cgroupReader
+	failed bool
+}
+
+func newLazyCgroupReader(root, disabled string) *lazyCgroupReader {
+	return &lazyCgroupReader{root: root, disabled: disabled}
+}
+
+// get returns the reader, nil while the daemon info isn't available, if the
+// cgroup filesystem can't be used or if l is nil.
+func (l *lazyCgroupReader) get(ctx context.Context, daemonInfo func(context.Context) (system.Info, error)) *cgroupReader {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.reader != nil || l.failed {
+		defer l.mu.Unlock()
+		return l.reader
+	}
+	l.mu.Unlock()
+
+	info, err := daemonInfo(ctx)
+	if err != nil {
+		log.Debug("can't get the daemon's cgroup driver yet: ", err)
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reader != nil || l.failed {
+		return l.reader
+	}
+	reader, err := newCgroupReader(l.root, info.CgroupDriver)
+	if err != nil {
+		log.Warnf("%s: %v", l.disabled, err)
+		l.failed = true
+		return nil
+	}
+	if l.needsV2 && !reader.v2 {
+		log.Infof("%s: the host uses cgroup v1", l.disabled)
+		l.failed = true
+		return nil
+	}
+	l.reader = reader
+	return reader
+}
+
+// path returns the cgroup of a container relative to the hierarchy root.
+func (r *cgroupReader) path(info *types.ContainerJSON) string {
+	var parent string
+	if info.HostConfig != nil {
+		parent = info.HostConfig.CgroupParent
+	}
+
+	if r.driver == "systemd" {
+		if parent == "" {
+			parent = "system.slice"
+		}
+		return filepath.Join(expandSlice(parent), "docker-"+info.ID+".scope")
+	}
+
+	if parent == "" {
+		parent = "/docker"
+	}
+	return filepath.Join(parent, info.ID)
+}
+
+// expandSlice turns a systemd slice name into its path, a-b.slice becomes
+// a.slice/a-b.slice.
+func expandSlice(slice string) string {
+	name, ok := strings.CutSuffix(slice, ".slice")
+	if !ok || name == "-" {
+		return slice
+	}
+
+	var path string
+	parts := strings.Split(name, "-")
+	for i := range parts {
+		path = filepath.Join(path, strings.Join(parts[:i+1], "-")+".slice")
+	}
+	return path
+}
+
+// stats reads the stats of a running container in the layout of the stats API
+// with the sample of the last call as previous sample.
+func (r *cgroupReader) stats(info *types.ContainerJSON) (*types.StatsJSON, error) {
+	stats, err := r.read(info)
+	if err != nil {
+		return nil, err
+	}
+	r.samples.fill(info.ID, stats)
+
+	return stats, nil
+}
+
+// read reads the stats of a running container without a previous sample.
+func (r *cgroupReader) read(info *types.ContainerJSON) (*types.StatsJSON, error) {
+	path := r.path(info)
+
+	stats := &types.StatsJSON{ID: info.ID, Name: info.Name}
+	stats.Read = time.Now()
+
+	var err error
+	if r.v2 {
+		err = r.readV2(path, stats)
+	} else {
+		err = r.readV1(path, stats)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.CPUStats.SystemUsage, stats.CPUStats.OnlineCPUs, err = systemCPUUsage(); err != nil {
+		return nil, err
+	}
+
+	if info.State != nil && info.State.Pid != 0 {
+		networks, err := readNetDev(fmt.Sprintf("/proc/%d/net/dev", info.State.Pid))
+		if err != nil {
+			log.Debugf("can't read network stats of container %s: %v", info.Name, err)
+		}
+		stats.Networks = networks
+	}
+
+	return stats, nil
+}
+
+// prune drops the previous CPU samples of all containers not in ids.
+func (r *cgroupReader) prune(ids map[string]bool) {
+	r.samples.prune(ids)
+}
+
+func (r *cgroupReader) readV2(path string, stats *types.StatsJSON) error {
+	dir := filepath.Join(r.root, path)
+
+	cpu, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	stats.CPUStats.CPUUsage.TotalUsage = cpu["usage_usec"] * 1000
+	stats.CPUStats.CPUUsage.UsageInKernelmode = cpu["system_usec"] * 1000
+	stats.CPUStats.CPUUsage.UsageInUsermode = cpu["user_usec"] * 1000
+	stats.CPUStats.ThrottlingData.Periods = cpu["nr_periods"]
+	stats.CPUStats.ThrottlingData.ThrottledPeriods = cpu["nr_throttled"]
+	stats.CPUStats.ThrottlingData.ThrottledTime = cpu["throttled_usec"] * 1000
+
+	if stats.MemoryStats.Usage, err = readUint(filepath.Join(dir, "memory.current")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(dir, "memory.stat")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Limit, err = readUint(filepath.Join(dir, "memory.max")); err != nil {
+		return err
+	}
+	if events, err := readKeyValues(filepath.Join(dir, "memory.events")); err == nil {
+		stats.MemoryStats.Stats["oom_kill"] = events["oom_kill"]
+	}
+
+	// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	if err := readLines(filepath.Join(dir, "io.stat"), func(fields []string) {
+		var major, minor uint64
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &major, &minor); err != nil {
+			return
+		}
+		for _, field := range fields[1:] {
+			key, raw, _ := strings.Cut(field, "=")
+			value, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				continue
+			}
+			entry := types.BlkioStatEntry{Major: major, Minor: minor, Value: value}
+			switch key {
+			case "rbytes", "wbytes":
+				entry.Op = map[string]string{"rbytes": "read", "wbytes": "write"}[key]
+				stats.BlkioStats.IoServiceBytesRecursive = append(stats.BlkioStats.IoServiceBytesRecursive, entry)
+			case "rios", "wios":
+				entry.Op = map[string]string{"rios": "read", "wios": "write"}[key]
+				stats.BlkioStats.IoServicedRecursive = append(stats.BlkioStats.IoServicedRecursive, entry)
+			}
+		}
+	}); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	stats.PidsStats.Current, _ = readUint(filepath.Join(dir, "pids.current"))
+	stats.PidsStats.Limit = readPidsMax(filepath.Join(dir, "pids.max"))
+
+	return nil
+}
+
+func (r *cgroupReader) readV1(path string, stats *types.StatsJSON) error {
+	var err error
+	if stats.CPUStats.CPUUsage.TotalUsage, err = readUint(filepath.Join(r.root, "cpuacct", path, "cpuacct.usage")); err != nil {
+		return err
+	}
+
+	if data, err := os.ReadFile(filepath.Join(r.root, "cpuacct", path, "cpuacct.usage_percpu")); err == nil {
+		for _, field := range strings.Fields(string(data)) {
+			usage, _ := strconv.ParseUint(field, 10, 64)
+			stats.CPUStats.CPUUsage.PercpuUsage = append(stats.CPUStats.CPUUsage.PercpuUsage, usage)
+		}
+	}
+
+	// only present with the cpu controller's CFS bandwidth control
+	if cpu, err := readKeyValues(filepath.Join(r.root, "cpu", path, "cpu.stat")); err == nil {
+		stats.CPUStats.ThrottlingData.Periods = cpu["nr_periods"]
+		stats.CPUStats.ThrottlingData.ThrottledPeriods = cpu["nr_throttled"]
+		stats.CPUStats.ThrottlingData.ThrottledTime = cpu["throttled_time"]
+	}
+
+	memory := filepath.Join(r.root, "memory", path)
+	if stats.MemoryStats.Usage, err = readUint(filepath.Join(memory, "memory.usage_in_bytes")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Stats, err = readKeyValues(filepath.Join(memory, "memory.stat")); err != nil {
+		return err
+	}
+	if stats.MemoryStats.Limit, err = readUint(filepath.Join(memory, "memory.limit_in_bytes")); err != nil {
+		return err
+	}
+	stats.MemoryStats.Failcnt, _ = readUint(filepath.Join(memory, "memory.failcnt"))
+
+	blkio := filepath.Join(r.root, "blkio", path)
+	if stats.BlkioStats.IoServiceBytesRecursive, err = readBlkioV1(blkio, "blkio.throttle.io_service_bytes"); err != nil {
+		return err
+	}
+	if stats.BlkioStats.IoServicedRecursive, err = readBlkioV1(blkio, "blkio.throttle.io_serviced"); err != nil {
+		return err
+	}
+
+	stats.PidsStats.Current, _ = readUint(filepath.Join(r.root, "pids", path, "pids.current"))
+	stats.PidsStats.Limit = readPidsMax(filepath.Join(r.root, "pids", path, "pids.max"))
+
+	return nil
+}
+
+// readBlkioV1 reads a cgroup v1 blkio file, preferring its _recursive variant.
+func readBlkioV1(dir, name string) ([]types.BlkioStatEntry, error) {
+	file := filepath.Join(dir, name+"_recursive")
+	if _, err := os.Stat(file); err != nil {
+		file = filepath.Join(dir, name)
+	}
+
+	// 8:0 Read 1459200
+	var entries []types.BlkioStatEntry
+	err := readLines(file, func(fields []string) {
+		var major, minor uint64
+		if len(fields) != 3 {
+			return
+		}
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &major, &minor); err != nil {
+			return
+		}
+		if value, err := strconv.ParseUint(fields[2], 10, 64); err == nil {
+			entries = append(entries, types.BlkioStatEntry{Major: major, Minor: minor, Op: fields[1], Value: value})
+		}
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// systemCPUUsage returns the host's CPU time in nanoseconds like the daemon
+// does, the sum of the first seven fields of the cpu line of /proc/stat, and
+// the number of online CPUs.
+func systemCPUUsage() (uint64, uint32, error) {
+	var usage uint64
+	var cpus uint32
+	var found bool
+	err := readLines("/proc/stat", func(fields []string) {
+		if strings.HasPrefix(fields[0], "cpu") && fields[0] != "cpu" {
+			cpus++
+			return
+		}
+		if fields[0] != "cpu" || found {
+			return
+		}
+		found = true
+		for _, field := range fields[1:min(len(fields), 8)] {
+			ticks, _ := strconv.ParseUint(field, 10, 64)
+			usage += ticks
+		}
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return 0, 0, errors.New("no cpu line in /proc/stat")
+	}
+
+	return usage * (1e9 / clockTicks), cpus, nil
+}
+
+// readNetDev reads the interface counters of a network namespace.
+func readNetDev(path string) (map[string]types.NetworkStats, error) {
+	networks := map[string]types.NetworkStats{}
+	err := readLines(path, func(fields []string) {
+		// eth0: rx_bytes rx_packets rx_errs rx_drop fifo frame compressed multicast tx_bytes tx_packets tx_errs tx_drop ...
+		name, ok := strings.CutSuffix(fields[0], ":")
+		// the stats API doesn't report the loopback interface either
+		if !ok || len(fields) < 17 || name == "lo" {
+			return
+		}
+		var v [16]uint64
+		for i := range v {
+			v[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+		}
+		networks[name] = types.NetworkStats{
+			RxBytes: v[0], RxPackets: v[1], RxErrors: v[2], RxDropped: v[3],
+			TxBytes: v[8], TxPackets: v[9], TxErrors: v[10], TxDropped: v[11],
+		}
+	})
+	return networks, err
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	// memory.max of cgroups without memory limit
+	if value == "max" {
+		return hostMemory()
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readPidsMax reads a pids limit, 0 if unlimited like in the stats API.
+func readPidsMax(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	limit, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return limit
+}
+
+// readKeyValues reads a flat keyed file like memory.stat or cpu.stat.
+func readKeyValues(path string) (map[string]uint64, error) {
+	values := map[string]uint64{}
+	err := readLines(path, func(fields []string) {
+		if len(fields) != 2 {
+			return
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	})
+	return values, err
+}
+
+// readLines calls fn with the fields of every non-empty line of a file.
+func readLines(path string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			fn(fields)
+		}
+	}
+	return scanner.Err()
+}
+
+// hostMemory returns the host's total memory, the limit the daemon reports for
+// containers without memory limit.
+func hostMemory() (uint64, error) {
+	var total uint64
+	err := readLines("/proc/meminfo", func(fields []string) {
+		// MemTotal:       16318000 kB
+		if fields[0] == "MemTotal:" && len(fields) == 3 {
+			kb, _ := strconv.ParseUint(fields[1], 10, 64)
+			total = kb * 1024
+		}
+	})
+	return total, err
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
+
+// ID of the container of the fixtures in testdata/cgroup
+const cgroupTestID = "4f1c2b8e9a7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b"
+
+func TestCgroupReaderStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		root   string
+		driver string
+		want   func(t *testing.T, stats *types.StatsJSON)
+	}{
+		{
+			name:   "cgroup v1",
+			root:   "testdata/cgroup/v1",
+			driver: "cgroupfs",
+			want: func(t *testing.T, stats *types.StatsJSON) {
+				wantUint(t, "total CPU usage", stats.CPUStats.CPUUsage.TotalUsage, 991375338955)
+				if got := len(stats.CPUStats.CPUUsage.PercpuUsage); got != 1 {
+					t.Errorf("per CPU usage of %d CPUs, want 1", got)
+				}
+				wantUint(t, "memory usage", stats.MemoryStats.Usage, 268025856)
+				wantUint(t, "memory limit", stats.MemoryStats.Limit, 9223372036854771712)
+				wantUint(t, "memory cache", stats.MemoryStats.Stats["cache"], 80605184)
+				wantUint(t, "total_inactive_file", stats.MemoryStats.Stats["total_inactive_file"], 53514240)
+
+				memory := containerMemory(stats)
+				wantUint(t, "usage", memory.usage, 268025856-80605184)
+				wantUint(t, "working set", memory.workingSet, 268025856-53514240)
+				if memory.cgroupVersion != 1 {
+					t.Errorf("cgroup version = %d, want 1", memory.cgroupVersion)
+				}
+			},
+		},
+		{
+			name:   "cgroup v2",
+			root:   "testdata/cgroup/v2",
+			driver: "systemd",
+			want: func(t *testing.T, stats *types.StatsJSON) {
+				wantUint(t, "total CPU usage", stats.CPUStats.CPUUsage.TotalUsage, 5132871000)
+				wantUint(t, "kernel mode CPU usage", stats.CPUStats.CPUUsage.UsageInKernelmode, 1217647000)
+				wantUint(t, "throttled periods", stats.CPUStats.ThrottlingData.ThrottledPeriods, 37)
+				wantUint(t, "throttled time", stats.CPUStats.ThrottlingData.ThrottledTime, 421345000)
+				wantUint(t, "memory usage", stats.MemoryStats.Usage, 52428800)
+				wantUint(t, "memory limit", stats.MemoryStats.Limit, 268435456)
+				wantUint(t, "OOM kills", stats.MemoryStats.Stats["oom_kill"], 1)
+				wantUint(t, "pids", stats.PidsStats.Current, 7)
+				wantUint(t, "pids limit", stats.PidsStats.Limit, 0)
+
+				memory := containerMemory(stats)
+				wantUint(t, "usage", memory.usage, 52428800)
+				wantUint(t, "working set", memory.workingSet, 52428800-12582912)
+				wantUint(t, "cache", memory.cache, 18874368)
+				if memory.cgroupVersion != 2 {
+					t.Errorf("cgroup version = %d, want 2", memory.cgroupVersion)
+				}
+
+				var read, write uint64
+				for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+					switch entry.Op {
+					case "read":
+						read += entry.Value
+					case "write":
+						write += entry.Value
+					}
+				}
+				wantUint(t, "read bytes", read, 1459200)
+				wantUint(t, "written bytes", write, 314773504)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newCgroupReader(tt.root, tt.driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info := &types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: cgroupTestID, Name: "/web", HostConfig: &container.HostConfig{}},
+			}
+			stats, err := r.stats(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want(t, stats)
+
+			// the second sample has the first one as previous sample
+			next, err := r.stats(info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.PreCPUStats.CPUUsage.TotalUsage != stats.CPUStats.CPUUsage.TotalUsage {
+				t.Errorf("previous CPU usage = %d, want %d", next.PreCPUStats.CPUUsage.TotalUsage, stats.CPUStats.CPUUsage.TotalUsage)
+			}
+		})
+	}
+}
+
+func TestNewCgroupReaderMissing(t *testing.T) {
+	if _, err := newCgroupReader("testdata/cgroup/missing", "cgroupfs"); err == nil {
+		t.Error("newCgroupReader() of a missing hierarchy succeeded")
+	}
+}
+
+func TestExpandSlice(t *testing.T) {
+	tests := map[string]string{
+		"system.slice":        "system.slice",
+		"user-1000.slice":     "user.slice/user-1000.slice",
+		"a-b-c.slice":         "a.slice/a-b.slice/a-b-c.slice",
+		"-.slice":             "-.slice",
+		"/custom/cgroup/path": "/custom/cgroup/path",
+	}
+	for slice, want := range tests {
+		if got := expandSlice(slice); got != want {
+			t.Errorf("expandSlice(%q) = %q, want %q", slice, got, want)
+		}
+	}
+}
+
+func TestCgroupStatsDaemonStartedLater(t *testing.T) {
+	ctr := types.Container{ID: cgroupTestID, Names: []string{"/web"}, State: "running"}
+	cli := &fakeDocker{
+		containers: []types.Container{ctr},
+		stats:      map[string]string{ctr.ID: statsCgroupV2},
+		inspects: map[string]types.ContainerJSON{ctr.ID: {
+			ContainerJSONBase: &types.ContainerJSONBase{ID: ctr.ID, Name: "/web", HostConfig: &container.HostConfig{}},
+		}},
+		infoErr: errors.New("daemon not running"),
+	}
+
+	tests := []struct {
+		root string
+		// memory usage once the daemon info is available
+		want uint64
+	}{
+		{root: "testdata/cgroup/v2", want: 52428800},
+		// the stats of the API if the cgroup filesystem can't be used
+		{root: "testdata/cgroup/missing", want: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.root, func(t *testing.T) {
+			cli.infoErr = errors.New("daemon not running")
+			cfg := testConfig()
+			cfg.statsSource = "cgroupfs"
+			cfg.statsCgroupRoot = tt.root
+			c := newDockerCollector(cfg, cli)
+
+			// the API's stats until the cgroup driver is known
+			stats, err := c.fetchStats(context.Background(), ctr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantUint(t, "memory usage without daemon info", stats.MemoryStats.Usage, 1000)
+
+			cli.infoErr = nil
+			cli.info = system.Info{CgroupDriver: "systemd"}
+			c.infoFailed = time.Now().Add(-infoRetryInterval)
+
+			stats, err = c.fetchStats(context.Background(), ctr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantUint(t, "memory usage with daemon info", stats.MemoryStats.Usage, tt.want)
+		})
+	}
+}
+
+func wantUint(t *testing.T, name string, got, want uint64) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("%s = %d, want %d", name, got, want)
+	}
+}
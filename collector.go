@@ -3,196 +3,1184 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
 	"github.com/docker/docker/client"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
+var (
+	upDesc = newDesc(
+		"up",
+		"1 if the docker daemon was reachable during the scrape, 0 otherwise",
+	)
+	lastScrapeDurationSecondsDesc = newDesc(
+		"last_scrape_duration_seconds",
+		"Duration of the collection of the docker metrics of the scrape",
+	)
+	scrapeTimeoutDesc = newDesc(
+		"scrape_timeout",
+		"1 if the docker API calls of the scrape were cancelled after --docker.timeout, 0 otherwise",
+	)
+	scrapeTimeoutSecondsDesc = newDesc(
+		"scrape_timeout_seconds",
+		"Configured timeout of the docker API calls of a scrape",
+	)
+	exporterContainersDroppedDesc = newDesc(
+		"exporter_containers_dropped",
+		"Number of containers not collected because of --containers.max",
+	)
+	containerRunningDesc = newContainerDesc(
+		"container_running",
+		"1 if docker container is running, 0 otherwise",
+	)
+	containerStateDesc = newContainerDesc(
+		"container_state",
+		"Current state of the container, value is 1 for the current state",
+		"state",
+	)
+	containerCreatedTimestampSecondsDesc = newContainerDesc(
+		"container_created_timestamp_seconds",
+		"Unix timestamp the container was created at",
+	)
+	containerStatsBackoffDesc = newContainerDesc(
+		"container_stats_backoff",
+		"1 if stats of the container are skipped after repeated failures, 0 otherwise",
+	)
+	containerScrapeDurationSecondsDesc = newContainerDesc(
+		"container_scrape_duration_seconds",
+		"Time it took to get the stats of the container during the scrape in seconds",
+	)
+	containerScrapeSuccessDesc = newContainerDesc(
+		"container_scrape_success",
+		"1 if the stats of the container were read during the scrape, 0 otherwise",
+	)
+	containerRestartsTotalDesc = newContainerDesc(
+		"container_restarts_total",
+		"Number of times the daemon restarted the container",
+	)
+	containerConfigInfoDesc = newContainerDesc(
+		"container_config_info",
+		"Restart policy, its maximum retry count and privileged mode of the container, value is always 1",
+		"restart_policy",
+		"restart_max_retries",
+		"privileged",
+	)
+	containerOOMKilledDesc = newContainerDesc(
+		"container_oom_killed",
+		"1 if the container's last run was ended by the OOM killer, 0 otherwise",
+	)
+	containerExitCodeDesc = newContainerDesc(
+		"container_exit_code",
+		"Exit code of the container's last run",
+	)
+	containerStartTimeSecondsDesc = newContainerDesc(
+		"container_start_time_seconds",
+		"Unix timestamp the container was last started at",
+	)
+	containerExecSessionsDesc = newContainerDesc(
+		"container_exec_sessions",
+		"Number of exec sessions of the container",
+	)
+	cpuUtilizationPercentDesc = newContainerDesc(
+		"cpu_utilization_percent",
+		"CPU utilization in percent, 100% per core",
+	)
+	cpuUtilizationOfLimitPercentDesc = newContainerDesc(
+		"cpu_utilization_of_limit_percent",
+		"CPU utilization in percent of the container's CPU limit",
+	)
+	cpuUtilizationSecondsTotalDesc = newContainerDesc(
+		"cpu_utilization_seconds_total",
+		"Cumulative CPU utilization in seconds",
+	)
+	cpuThrottlingPeriodsTotalDesc = newContainerDesc(
+		"cpu_throttling_periods_total",
+		"Number of CPU quota enforcement periods the container ran in",
+	)
+	cpuThrottledPeriodsTotalDesc = newContainerDesc(
+		"cpu_throttled_periods_total",
+		"Number of CPU quota enforcement periods the container was throttled in",
+	)
+	cpuThrottledSecondsTotalDesc = newContainerDesc(
+		"cpu_throttled_seconds_total",
+		"Cumulative time the container was throttled in seconds",
+	)
+	cpuUsageSecondsTotalDesc = newContainerDesc(
+		"cpu_usage_seconds_total",
+		"Cumulative CPU usage per core in seconds",
+		"cpu",
+	)
+	networkRxBytesTotalDesc = newContainerDesc(
+		"network_rx_bytes_total",
+		"Network received bytes total",
+	)
+	networkTxBytesTotalDesc = newContainerDesc(
+		"network_tx_bytes_total",
+		"Network sent bytes total",
+	)
+	networkRxPacketsTotalDesc = newContainerDesc(
+		"network_rx_packets_total",
+		"Network received packets total",
+	)
+	networkTxPacketsTotalDesc = newContainerDesc(
+		"network_tx_packets_total",
+		"Network sent packets total",
+	)
+	networkRxErrorsTotalDesc = newContainerDesc(
+		"network_rx_errors_total",
+		"Network receive errors total",
+	)
+	networkTxErrorsTotalDesc = newContainerDesc(
+		"network_tx_errors_total",
+		"Network send errors total",
+	)
+	networkRxDroppedTotalDesc = newContainerDesc(
+		"network_rx_dropped_total",
+		"Network received packets dropped total",
+	)
+	networkTxDroppedTotalDesc = newContainerDesc(
+		"network_tx_dropped_total",
+		"Network sent packets dropped total",
+	)
+	networkInterfaceRxBytesTotalDesc = newContainerDesc(
+		"network_interface_rx_bytes_total",
+		"Network received bytes total per interface",
+		"interface",
+	)
+	networkInterfaceTxBytesTotalDesc = newContainerDesc(
+		"network_interface_tx_bytes_total",
+		"Network sent bytes total per interface",
+		"interface",
+	)
+	memoryUsageBytesDesc = newContainerDesc(
+		"memory_usage_bytes",
+		"Total memory usage bytes",
+	)
+	memoryTotalBytesDesc = newContainerDesc(
+		"memory_total_bytes",
+		"Total memory bytes",
+	)
+	memoryUtilizationPercentDesc = newContainerDesc(
+		"memory_utilization_percent",
+		"Memory utilization percent",
+	)
+	memoryLimitSetDesc = newContainerDesc(
+		"memory_limit_set",
+		"1 if the container has a memory limit, 0 otherwise",
+	)
+	memoryWorkingSetBytesDesc = newContainerDesc(
+		"memory_working_set_bytes",
+		"Memory usage minus inactive file cache in bytes",
+	)
+	memoryCacheBytesDesc = newContainerDesc(
+		"memory_cache_bytes",
+		"Page cache memory in bytes",
+	)
+	memoryCgroupVersionDesc = newContainerDesc(
+		"memory_cgroup_version",
+		"cgroup version detected from the container's memory stats, 1 or 2, 0 if unknown",
+	)
+	memoryFailcntTotalDesc = newContainerDesc(
+		"memory_failcnt_total",
+		"Number of times the container's memory usage hit its limit",
+	)
+	memoryOOMEventsTotalDesc = newContainerDesc(
+		"memory_oom_events_total",
+		"Number of processes of the container killed by the OOM killer",
+	)
+	blockIOReadBytesTotalDesc = newContainerDesc(
+		"block_io_read_bytes_total",
+		"Block I/O read bytes",
+	)
+	blockIOWriteBytesTotalDesc = newContainerDesc(
+		"block_io_write_bytes_total",
+		"Block I/O write bytes",
+	)
+	blockIODeviceReadBytesTotalDesc = newContainerDesc(
+		"block_io_device_read_bytes_total",
+		"Block I/O read bytes per device",
+		"device",
+	)
+	blockIODeviceWriteBytesTotalDesc = newContainerDesc(
+		"block_io_device_write_bytes_total",
+		"Block I/O write bytes per device",
+		"device",
+	)
+	blockIOReadsTotalDesc = newContainerDesc(
+		"block_io_reads_total",
+		"Block I/O read operations",
+	)
+	blockIOWritesTotalDesc = newContainerDesc(
+		"block_io_writes_total",
+		"Block I/O write operations",
+	)
+	pidsCurrentDesc = newContainerDesc(
+		"pids_current",
+		"Current number of pids in the cgroup",
+	)
+	pidsLimitDesc = newContainerDesc(
+		"pids_limit",
+		"Maximum number of pids in the cgroup",
+	)
+)
+
+// errStatsBackoff is returned for containers whose stats are skipped after repeated failures
+var errStatsBackoff = errors.New("stats skipped during backoff")
+
+// smallest limit reported for cgroup v1 containers without memory limit
+// (max int64 aligned to pages of up to 64k)
+const unlimitedMemory = 0x7FFFFFFFFFFF0000
+
+// minimum time between two daemon info calls after a failed one
+const infoRetryInterval = 10 * time.Second
+
+// minimum time between two warnings about dropped containers
+const dropWarningInterval = 10 * time.Minute
+
 var labelCname = []string{"container_name"}
 
 type DockerCollector struct {
-	cli *client.Client
+	cli      dockerAPI
+	cfg      *config
+	devices  *blockDevices
+	inspects *inspectCache
+	images   *imageCache
+	backoff  *statsBackoff
+	daemon   *daemonBackoff
+	errors   *containerErrorLog
+	gpus     gpuReader
+	sockets  *socketReader
+	prober   *portProber
+	cgroups  *lazyCgroupReader
+	pressure *lazyCgroupReader
+	labelMap *labelMap
+
+	// failed container list and stats calls
+	scrapeErrors *prometheus.CounterVec
+
+	// limits the concurrent stats calls to the daemon, nil if unlimited
+	statsSlots chan struct{}
+	// set if the stats are refreshed in the background, --stats.interval
+	statsCache *statsCache
+
+	// stats streams of the running containers with --stats.mode=stream
+	statsStreams *statsStreams
+
+	// previous CPU samples of the one-shot API stats
+	cpuSamples *cpuSamples
+
+	// removed containers still reported, nil without --containers.absent-grace
+	absent *absentContainers
+
+	infoMu     sync.Mutex
+	info       *system.Info
+	infoErr    error
+	infoFailed time.Time
+	infoCalls  singleflight.Group
+
+	descsMu sync.Mutex
+	descs   *descSet
+
+	dropWarningMu   sync.Mutex
+	lastDropWarning time.Time
+
+	// false if --metrics.only excludes all metrics of the API call
+	wantStats     bool
+	wantInspect   bool
+	wantImageSize bool
 }
 
-func newDockerCollector() *DockerCollector {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Fatalf("can't create docker client: %v", err)
+func newDockerCollector(cfg *config, cli dockerAPI) *DockerCollector {
+	var prober *portProber
+	if cfg.portProbe {
+		prober = &portProber{
+			address: cfg.portProbeAddress,
+			timeout: cfg.portProbeTimeout,
+			budget:  cfg.portProbeBudget,
+		}
+	}
+
+	var statsSlots chan struct{}
+	if cfg.statsMaxConcurrent > 0 {
+		statsSlots = make(chan struct{}, cfg.statsMaxConcurrent)
+	}
+
+	var cgroups *lazyCgroupReader
+	if cfg.statsSource == "cgroupfs" {
+		cgroups = newLazyCgroupReader(cfg.statsCgroupRoot, "cgroupfs stats disabled, reading stats from the docker API")
+	}
+
+	var pressure *lazyCgroupReader
+	if cfg.pressure {
+		// pressure stall information only exists for cgroup v2
+		pressure = newLazyCgroupReader(cfg.statsCgroupRoot, "pressure collector disabled")
+		pressure.needsV2 = true
+	}
+
+	var absent *absentContainers
+	if cfg.containersAbsentGrace > 0 {
+		absent = newAbsentContainers(cfg.containersAbsentGrace)
 	}
 
 	return &DockerCollector{
-		cli: cli,
+		cli:      cli,
+		cfg:      cfg,
+		devices:  newBlockDevices(),
+		inspects: newInspectCache(cli),
+		images:   newImageCache(cli),
+		backoff:  newStatsBackoff(cfg.statsFailureThreshold, cfg.statsFailureBackoff),
+		daemon:   &daemonBackoff{},
+		errors:   newContainerErrorLog(),
+		prober:   prober,
+		cgroups:  cgroups,
+		pressure: pressure,
+		absent:   absent,
+
+		statsSlots: statsSlots,
+		cpuSamples: newCPUSamples(),
+		scrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "scrape_errors_total",
+			Help:      "Number of failed container list, inspect and stats calls of scrapes, per container for the latter",
+		}, []string{"operation", "container_name"}),
+
+		wantStats:     cfg.anyAllowed(statsMetrics...),
+		wantInspect:   cfg.anyAllowed(inspectMetrics...),
+		wantImageSize: cfg.anyAllowed("container_image_size_bytes"),
 	}
 }
 
-func (c *DockerCollector) Describe(_ chan<- *prometheus.Desc) {
-
+func (c *DockerCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.descriptors(c.labelMapping()).describe(ch)
 }
 
 func (c *DockerCollector) Collect(ch chan<- prometheus.Metric) {
-	containers, err := c.cli.ContainerList(context.Background(), container.ListOptions{
-		All: true,
+	mapping := c.labelMapping()
+	descs := c.descriptors(mapping)
+
+	if !c.cfg.legacyNames {
+		c.collect(ch, mapping, descs)
+		return
+	}
+
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		legacyMetrics(metrics, ch, descs, c.cfg.namespace)
+		close(done)
+	}()
+
+	c.collect(metrics, mapping, descs)
+	close(metrics)
+	<-done
+}
+
+func (c *DockerCollector) collect(ch chan<- prometheus.Metric, mapping *labelMapping, descs *descSet) {
+	// outstanding API calls are cancelled once the timeout is reached, whatever
+	// was collected until then is still reported
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.dockerTimeout)
+	defer cancel()
+
+	defer c.scrapeMetrics(ctx, start, ch, descs)
+
+	if c.daemon.skip() {
+		ch <- prometheus.MustNewConstMetric(descs.get(upDesc), prometheus.GaugeValue, 0)
+		return
+	}
+
+	listStart := time.Now()
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
+		All: c.cfg.containersAll,
 	})
-	if err != nil {
+	log.Debugf("listed %d containers in %v", len(containers), time.Since(listStart))
+	switch {
+	case client.IsErrConnectionFailed(err):
+		c.daemon.failure(err)
+		c.scrapeErrors.WithLabelValues("container_list", "").Inc()
+	case err != nil:
 		log.Error("can't list containers: ", err)
+		c.scrapeErrors.WithLabelValues("container_list", "").Inc()
+	default:
+		c.daemon.success()
+	}
+
+	var up float64
+	if err == nil {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(descs.get(upDesc), prometheus.GaugeValue, up)
+
+	if err != nil {
 		return
 	}
 
+	if c.cfg.normalizeSwarmNames {
+		containers = dedupeSwarmTasks(containers)
+	}
+	containers = c.limitContainers(ch, descs, containers)
+
 	var wg sync.WaitGroup
 
+	agg := newHostAggregate(descs)
+	var probeDeadline time.Time
+	if c.prober != nil {
+		probeDeadline = time.Now().Add(c.prober.budget)
+	}
+	if c.statsStreams != nil && c.wantStats {
+		running := map[string]string{}
+		for _, container := range containers {
+			if container.State == "running" {
+				running[container.ID] = containerName(container)
+			}
+		}
+		c.statsStreams.sync(running)
+	}
+
+	ids := map[string]bool{}
 	for _, container := range containers {
+		ids[container.ID] = true
 		wg.Add(1)
 
-		go c.processContainer(container, ch, &wg)
+		go c.processContainer(ctx, container, ch, agg, mapping, descs, probeDeadline, &wg)
 	}
 	wg.Wait()
+
+	c.inspects.prune(ids)
+	c.backoff.prune(ids)
+	c.cpuSamples.prune(ids)
+	for _, cName := range c.errors.prune(ids) {
+		c.scrapeErrors.DeletePartialMatch(prometheus.Labels{"container_name": cName})
+	}
+	if cgroups := c.cgroups.get(ctx, c.daemonInfo); cgroups != nil {
+		cgroups.prune(ids)
+	}
+	if c.statsCache != nil {
+		c.statsCache.prune(ids)
+	}
+	if c.absent != nil {
+		c.absent.collect(ch, descs, listStart)
+	}
+	c.inspects.collect(ch, descs)
+	if c.statsCache != nil {
+		c.statsCache.collect(ch, descs)
+	}
+	if c.statsStreams != nil {
+		c.statsStreams.collect(ch, descs)
+	}
+	c.images.expire()
+	c.images.collect(ch, descs)
+
+	agg.collectStates(ch)
+	agg.collectImages(ch)
+	if c.cfg.aggregateOnly || c.cfg.hostAggregates {
+		agg.collect(ch)
+	}
+}
+
+func (c *DockerCollector) scrapeMetrics(ctx context.Context, start time.Time, ch chan<- prometheus.Metric, descs *descSet) {
+	ch <- prometheus.MustNewConstMetric(descs.get(lastScrapeDurationSecondsDesc), prometheus.GaugeValue, time.Since(start).Seconds())
+	ch <- prometheus.MustNewConstMetric(descs.get(scrapeTimeoutDesc), prometheus.GaugeValue, boolToFloat(errors.Is(ctx.Err(), context.DeadlineExceeded)))
+	ch <- prometheus.MustNewConstMetric(descs.get(scrapeTimeoutSecondsDesc), prometheus.GaugeValue, c.cfg.dockerTimeout.Seconds())
 }
 
-func (c *DockerCollector) processContainer(container types.Container, ch chan<- prometheus.Metric, wg *sync.WaitGroup) {
+// limitContainers keeps the newest --containers.max containers and reports how
+// many were dropped.
+func (c *DockerCollector) limitContainers(ch chan<- prometheus.Metric, descs *descSet, containers []types.Container) []types.Container {
+	var dropped int
+	if c.cfg.maxContainers > 0 && len(containers) > c.cfg.maxContainers {
+		sort.SliceStable(containers, func(i, j int) bool {
+			return containers[i].Created > containers[j].Created
+		})
+		dropped = len(containers) - c.cfg.maxContainers
+		containers = containers[:c.cfg.maxContainers]
+
+		c.dropWarningMu.Lock()
+		if time.Since(c.lastDropWarning) >= dropWarningInterval {
+			log.Warnf("%d containers exceed --containers.max=%d, dropping %d of them", dropped+c.cfg.maxContainers, c.cfg.maxContainers, dropped)
+			c.lastDropWarning = time.Now()
+		}
+		c.dropWarningMu.Unlock()
+	}
+
+	ch <- prometheus.MustNewConstMetric(descs.get(exporterContainersDroppedDesc), prometheus.GaugeValue, float64(dropped))
+
+	return containers
+}
+
+// labelMapping returns the label map to use for a scrape.
+func (c *DockerCollector) labelMapping() *labelMapping {
+	if c.labelMap == nil {
+		return &labelMapping{}
+	}
+	return c.labelMap.current()
+}
+
+// descriptors returns the metric descriptors for the container labels of a
+// scrape. They are only recreated when the label names change.
+func (c *DockerCollector) descriptors(mapping *labelMapping) *descSet {
+	names := []string{"container_name"}
+	if c.cfg.labelsContainerID != "none" {
+		names = append(names, "container_id")
+	}
+	if c.cfg.labelsSwarm {
+		names = append(names, swarmLabelNames...)
+	}
+	if c.cfg.labelsCompose {
+		names = append(names, composeLabelNames...)
+	}
+	names = append(append(names, dockerLabelNames(c.cfg.dockerLabels)...), mapping.names...)
+
+	c.descsMu.Lock()
+	defer c.descsMu.Unlock()
+
+	if c.descs == nil || !slices.Equal(c.descs.containerLabels, names) {
+		c.descs = newDescSet(c.cfg, names)
+	}
+	return c.descs
+}
+
+// daemonInfo returns the docker daemon info. It's fetched once and cached. A
+// failed lookup is cached for infoRetryInterval, so an unreachable daemon isn't
+// asked again for every container of the scrape. Concurrent lookups share a
+// single call.
+func (c *DockerCollector) daemonInfo(ctx context.Context) (system.Info, error) {
+	c.infoMu.Lock()
+	if c.info != nil {
+		defer c.infoMu.Unlock()
+		return *c.info, nil
+	}
+	if c.infoErr != nil && time.Since(c.infoFailed) < infoRetryInterval {
+		defer c.infoMu.Unlock()
+		return system.Info{}, c.infoErr
+	}
+	c.infoMu.Unlock()
+
+	info, err, _ := c.infoCalls.Do("info", func() (any, error) {
+		info, err := c.cli.Info(ctx)
+
+		c.infoMu.Lock()
+		defer c.infoMu.Unlock()
+		switch {
+		case err == nil:
+			c.info, c.infoErr = &info, nil
+		case ctx.Err() == nil:
+			// a timed out scrape says nothing about the daemon
+			c.infoErr, c.infoFailed = err, time.Now()
+		}
+		return info, err
+	})
+	return info.(system.Info), err
+}
+
+// warmUp runs a single collection and discards the result.
+func (c *DockerCollector) warmUp() {
+	start := time.Now()
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+
+	c.Collect(ch)
+	close(ch)
+	<-done
+
+	log.Debugf("warm-up collection finished in %v", time.Since(start))
+}
+
+func (c *DockerCollector) processContainer(ctx context.Context, container types.Container, ch chan<- prometheus.Metric, agg *hostAggregate, mapping *labelMapping, descs *descSet, probeDeadline time.Time, wg *sync.WaitGroup) {
 	defer wg.Done()
-	cName := strings.TrimPrefix(strings.Join(container.Names, ";"), "/")
+	agg.addContainer(container)
+
+	if c.cfg.aggregateOnly {
+		if container.State == "running" && c.wantStats {
+			if containerStats, err := c.containerStats(ctx, container); err == nil {
+				agg.addStats(containerStats, c.hostCPUs(ctx))
+			}
+		}
+		return
+	}
+
+	cName := containerName(container)
+	if c.cfg.normalizeSwarmNames {
+		cName = normalizeSwarmName(cName, container.Labels)
+	}
+	values := []string{cName}
+	switch c.cfg.labelsContainerID {
+	case "short":
+		values = append(values, shortID(container.ID))
+	case "full":
+		values = append(values, container.ID)
+	}
+	if c.cfg.labelsSwarm {
+		values = append(values, swarmLabelValues(container.Labels)...)
+	}
+	if c.cfg.labelsCompose {
+		values = append(values, composeLabelValues(container.Labels)...)
+	}
+	l := &containerLabels{
+		descs:  descs,
+		values: append(append(values, dockerLabelValues(c.cfg.dockerLabels, container.Labels)...), mapping.labels(cName, container.Labels)...),
+	}
 	var isRunning float64
 	if container.State == "running" {
 		isRunning = 1
 	}
 
 	// container state metric for all containers
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_container_running",
-		"1 if docker container is running, 0 otherwise",
-		labelCname,
-		nil,
-	), prometheus.GaugeValue, isRunning, cName)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerRunningDesc), prometheus.GaugeValue, isRunning, l.values...)
 
-	// stats metrics only for running containers
-	if isRunning == 1 {
+	seen := time.Now()
+	ch <- prometheus.MustNewConstMetric(l.desc(containerLastSeenTimestampSecondsDesc), prometheus.GaugeValue, float64(seen.UnixNano())/1e9, l.values...)
+	if c.absent != nil {
+		c.absent.seen(l, seen)
+	}
 
-		if stats, err := c.cli.ContainerStats(context.Background(), container.ID, false); err != nil {
-			log.Fatal(err)
+	for _, state := range containerStateNames {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerStateDesc), prometheus.GaugeValue, boolToFloat(container.State == state), l.valuesWith(state)...)
+	}
+
+	c.infoMetrics(ctx, ch, container, l)
+
+	c.networkInfoMetrics(ch, container, l)
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerCreatedTimestampSecondsDesc), prometheus.GaugeValue, float64(container.Created), l.values...)
+
+	if c.wantImageSize {
+		c.imageSizeMetrics(ctx, ch, container, l)
+	}
+
+	// inspect metrics for all containers
+	var info types.ContainerJSON
+	var inspected bool
+	if c.wantInspect {
+		var err error
+		if info, err = c.inspects.get(ctx, container); err != nil {
+			c.errors.log(container.ID, cName, "can't inspect container", err)
+			c.scrapeErrors.WithLabelValues("container_inspect", cName).Inc()
 		} else {
-			var containerStats types.StatsJSON
-			err := json.NewDecoder(stats.Body).Decode(&containerStats)
-			if err != nil {
-				log.Error("can't read api stats: ", err)
+			inspected = true
+			l.started, _ = containerStartedAt(&info)
+
+			c.securityMetrics(ctx, ch, &info, l)
+
+			c.limitMetrics(ch, &info, l)
+
+			c.healthcheckMetrics(ch, &info, l)
+
+			c.restartMetrics(ch, &info, l)
+
+			c.startTimeMetrics(ch, &info, l)
+
+			if c.cfg.devices {
+				c.deviceMetrics(ch, &info, l)
 			}
-			if err := stats.Body.Close(); err != nil {
-				log.Error("can't close body: ", err)
+		}
+	}
+
+	// stats metrics only for running containers, the stats call blocks on
+	// paused containers with some daemon versions
+	if isRunning == 1 {
+
+		if c.wantStats {
+			ch <- prometheus.MustNewConstMetric(l.desc(containerStatsBackoffDesc), prometheus.GaugeValue, boolToFloat(c.backoff.skip(container.ID)), l.values...)
+
+			// only the stats call, not the metrics sent below
+			start := time.Now()
+			containerStats, err := c.containerStats(ctx, container)
+			ch <- prometheus.MustNewConstMetric(l.desc(containerScrapeDurationSecondsDesc), prometheus.GaugeValue, time.Since(start).Seconds(), l.values...)
+			ch <- prometheus.MustNewConstMetric(l.desc(containerScrapeSuccessDesc), prometheus.GaugeValue, boolToFloat(err == nil), l.values...)
+
+			if err == nil {
+				hostCPUs := c.hostCPUs(ctx)
+				agg.addStats(containerStats, hostCPUs)
+
+				var limit float64
+				if inspected {
+					limit, _ = cpuLimit(info.HostConfig)
+				}
+
+				c.networkMetrics(ch, containerStats, l)
+
+				// no blkio and pids stats on Windows
+				if isWindowsStats(containerStats) {
+					c.windowsMemoryMetrics(ch, containerStats, l)
+
+					c.windowsCPUMetrics(ch, containerStats, limit, l)
+				} else {
+					c.blockIoMetrics(ch, containerStats, l)
+
+					c.memoryMetrics(ctx, ch, containerStats, l)
+
+					c.CPUMetrics(ch, containerStats, limit, hostCPUs, l)
+
+					c.pidsMetrics(ch, containerStats, l)
+				}
 			}
+		}
 
-			c.blockIoMetrics(ch, &containerStats, cName)
+		if inspected {
+			c.execMetrics(ch, &info, l)
 
-			c.memoryMetrics(ch, &containerStats, cName)
+			c.imageMetrics(ctx, ch, container, &info, l)
 
-			c.networkMetrics(ch, &containerStats, cName)
+			if c.cfg.tmpfs {
+				c.tmpfsMetrics(ch, &info, l)
+			}
+
+			if c.gpus != nil {
+				c.gpuMetrics(ch, &info, l)
+			}
+
+			if c.sockets != nil {
+				c.connectionMetrics(ch, &info, l)
+			}
+
+			if pressure := c.pressure.get(ctx, c.daemonInfo); pressure != nil && isRunning == 1 {
+				c.pressureMetrics(ch, pressure, &info, l)
+			}
+		}
+
+		if c.prober != nil {
+			c.probeMetrics(ch, container, probeDeadline, l)
+		}
+	}
+}
+
+// containerStats returns the stats of a running container, from the stats
+// cache if enabled.
+func (c *DockerCollector) containerStats(ctx context.Context, container types.Container) (*types.StatsJSON, error) {
+	if c.statsCache != nil {
+		return c.statsCache.get(container.ID)
+	}
+	if c.statsStreams != nil {
+		return c.statsStreams.get(container.ID)
+	}
+	return c.fetchStats(ctx, container)
+}
+
+// fetchStats fetches a single stats sample of a running container.
+// Containers whose stats failed repeatedly are skipped during their backoff.
+func (c *DockerCollector) fetchStats(ctx context.Context, container types.Container) (*types.StatsJSON, error) {
+	if c.backoff.skip(container.ID) {
+		log.WithField("container", containerName(container)).Debug("stats skipped after repeated failures")
+		return nil, errStatsBackoff
+	}
+
+	start := time.Now()
+	var containerStats *types.StatsJSON
+	var err error
+	// the API's stats until the cgroup driver is known
+	if cgroups := c.cgroups.get(ctx, c.daemonInfo); cgroups != nil {
+		containerStats, err = c.cgroupStats(ctx, cgroups, container)
+	} else {
+		containerStats, err = c.apiStats(ctx, container)
+	}
+	log.WithField("container", containerName(container)).Debugf("stats fetched in %v", time.Since(start))
+	if err != nil {
+		// the scrape timed out, not the container's fault
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		c.scrapeErrors.WithLabelValues("container_stats", containerName(container)).Inc()
+		c.backoff.failure(container.ID, containerName(container))
+		return nil, err
+	}
+
+	c.backoff.success(container.ID)
+	return containerStats, nil
+}
+
+// apiStats reads a single stats sample from the daemon. The daemon's one-shot
+// stats have no previous sample, the one of the last scrape is used instead.
+func (c *DockerCollector) apiStats(ctx context.Context, container types.Container) (*types.StatsJSON, error) {
+	containerStats, err := c.readAPIStats(ctx, container.ID)
+	if err != nil {
+		// e.g. the container was removed since it was listed
+		c.errors.log(container.ID, containerName(container), "can't get api stats", err)
+		return nil, err
+	}
+	c.cpuSamples.fill(container.ID, containerStats)
+
+	return containerStats, nil
+}
+
+// readAPIStats reads a one-shot stats sample from the daemon, at most
+// --stats.max-concurrent at a time.
+func (c *DockerCollector) readAPIStats(ctx context.Context, id string) (*types.StatsJSON, error) {
+	if c.statsSlots != nil {
+		select {
+		case c.statsSlots <- struct{}{}:
+			defer func() { <-c.statsSlots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	stats, err := c.cli.ContainerStatsOneShot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var containerStats types.StatsJSON
+	err = json.NewDecoder(stats.Body).Decode(&containerStats)
+	if err := stats.Body.Close(); err != nil {
+		log.Error("can't close body: ", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &containerStats, nil
+}
 
-			c.CPUMetrics(ch, &containerStats, cName)
+// cgroupStats reads the stats from the container's cgroup, --stats.source=cgroupfs.
+func (c *DockerCollector) cgroupStats(ctx context.Context, cgroups *cgroupReader, container types.Container) (*types.StatsJSON, error) {
+	info, err := c.inspects.get(ctx, container)
+	if err != nil {
+		c.errors.log(container.ID, containerName(container), "can't inspect container", err)
+		return nil, err
+	}
+
+	containerStats, err := cgroups.stats(&info)
+	if err != nil {
+		c.errors.log(container.ID, containerName(container), "can't read cgroup stats", err)
+		return nil, err
+	}
 
-			c.pidsMetrics(ch, &containerStats, cName)
+	return containerStats, nil
+}
+
+// containerName returns the name of a container without the leading slash.
+func containerName(container types.Container) string {
+	return strings.TrimPrefix(strings.Join(container.Names, ";"), "/")
+}
+
+// shortID truncates a container ID to 12 characters like the docker CLI does.
+func shortID(id string) string {
+	return id[:min(len(id), 12)]
+}
+
+func (c *DockerCollector) restartMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	ch <- prometheus.MustNewConstMetric(l.desc(containerRestartsTotalDesc), prometheus.CounterValue, float64(info.RestartCount), l.values...)
+
+	if info.HostConfig != nil {
+		// containers created without restart policy have an empty name, which means no
+		policy := string(info.HostConfig.RestartPolicy.Name)
+		if policy == "" {
+			policy = "no"
 		}
+		ch <- prometheus.MustNewConstMetric(l.desc(containerConfigInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(
+			policy,
+			strconv.Itoa(info.HostConfig.RestartPolicy.MaximumRetryCount),
+			strconv.FormatBool(info.HostConfig.Privileged),
+		)...)
 	}
+
+	if info.State == nil || info.State.Running {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerOOMKilledDesc), prometheus.GaugeValue, boolToFloat(info.State.OOMKilled), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerExitCodeDesc), prometheus.GaugeValue, float64(info.State.ExitCode), l.values...)
+}
+
+func (c *DockerCollector) startTimeMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	startedAt, ok := containerStartedAt(info)
+	if !ok {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerStartTimeSecondsDesc), prometheus.GaugeValue, float64(startedAt.UnixNano())/1e9, l.values...)
+}
+
+// containerStartedAt returns the time the container was last started, false
+// if it never ran.
+func containerStartedAt(info *types.ContainerJSON) (time.Time, bool) {
+	if info.State == nil {
+		return time.Time{}, false
+	}
+
+	// containers that never ran have the zero time
+	startedAt, err := time.Parse(time.RFC3339Nano, info.State.StartedAt)
+	if err != nil || startedAt.IsZero() || startedAt.Unix() <= 0 {
+		return time.Time{}, false
+	}
+	return startedAt, true
+}
+
+func (c *DockerCollector) execMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	ch <- prometheus.MustNewConstMetric(l.desc(containerExecSessionsDesc), prometheus.GaugeValue, float64(len(info.ExecIDs)), l.values...)
 }
 
-func (c *DockerCollector) CPUMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, cName string) {
+// CPUMetrics reports the CPU usage of a container, relative to limitCPUs as
+// well if the container is limited (limitCPUs > 0).
+func (c *DockerCollector) CPUMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, limitCPUs float64, hostCPUs uint32, l *containerLabels) {
+	if utilization, ok := cpuUtilization(containerStats, hostCPUs); ok {
+		ch <- prometheus.MustNewConstMetric(l.desc(cpuUtilizationPercentDesc), prometheus.GaugeValue, utilization, l.values...)
+		if limitCPUs > 0 {
+			ch <- prometheus.MustNewConstMetric(l.desc(cpuUtilizationOfLimitPercentDesc), prometheus.GaugeValue, utilization/limitCPUs, l.values...)
+		}
+	}
+
+	ch <- l.counter(cpuUtilizationSecondsTotalDesc, cpuSeconds(containerStats), l.values...)
+
+	throttling := containerStats.CPUStats.ThrottlingData
+	ch <- l.counter(cpuThrottlingPeriodsTotalDesc, float64(throttling.Periods), l.values...)
+	ch <- l.counter(cpuThrottledPeriodsTotalDesc, float64(throttling.ThrottledPeriods), l.values...)
+	ch <- l.counter(cpuThrottledSecondsTotalDesc, float64(throttling.ThrottledTime)/1e9, l.values...)
+
+	// only reported on cgroup v1
+	if c.cfg.percpu {
+		for cpu, usage := range containerStats.CPUStats.CPUUsage.PercpuUsage {
+			ch <- l.counter(cpuUsageSecondsTotalDesc, float64(usage)/1e9, l.valuesWith(strconv.Itoa(cpu))...)
+		}
+	}
+}
+
+// cpuUtilization returns the CPU utilization of a container between the
+// current and the previous sample of the stats, 100% per core.
+func cpuUtilization(containerStats *types.StatsJSON, hostCPUs uint32) (float64, bool) {
+	if isWindowsStats(containerStats) {
+		return windowsCPUUtilization(containerStats)
+	}
+
+	// the first sample of a container has no previous values and identical
+	// samples have no delta, there's no utilization to compute in both cases
 	totalUsage := containerStats.CPUStats.CPUUsage.TotalUsage
-	cpuDelta := totalUsage - containerStats.PreCPUStats.CPUUsage.TotalUsage
-	sysemDelta := containerStats.CPUStats.SystemUsage - containerStats.PreCPUStats.SystemUsage
+	preCPU := containerStats.PreCPUStats
+	if preCPU.SystemUsage == 0 || containerStats.CPUStats.SystemUsage <= preCPU.SystemUsage || totalUsage < preCPU.CPUUsage.TotalUsage {
+		return 0, false
+	}
 
-	cpuUtilization := float64(cpuDelta) / float64(sysemDelta) * 100.0
+	cpuDelta := totalUsage - preCPU.CPUUsage.TotalUsage
+	systemDelta := containerStats.CPUStats.SystemUsage - preCPU.SystemUsage
 
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_cpu_utilization_percent",
-		"CPU utilization in percent",
-		labelCname,
-		nil,
-	), prometheus.GaugeValue, cpuUtilization, cName)
+	// like docker stats, there are no per CPU usages with cgroup v2
+	onlineCPUs := containerStats.CPUStats.OnlineCPUs
+	if onlineCPUs == 0 {
+		onlineCPUs = uint32(len(containerStats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if onlineCPUs == 0 {
+		onlineCPUs = hostCPUs
+	}
 
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_cpu_utilization_seconds_total",
-		"Cumulative CPU utilization in seconds",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(totalUsage)/1e9, cName)
+	return float64(cpuDelta) / float64(systemDelta) * float64(onlineCPUs) * 100.0, true
 }
 
-func (c *DockerCollector) networkMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, cName string) {
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_network_rx_bytes",
-		"Network received bytes total",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(containerStats.Networks["eth0"].RxBytes), cName)
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_network_tx_bytes",
-		"Network sent bytes total",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(containerStats.Networks["eth0"].TxBytes), cName)
+// hostCPUs returns the number of CPUs of the daemon's host, the one of dex's
+// host while the daemon info is unavailable.
+func (c *DockerCollector) hostCPUs(ctx context.Context) uint32 {
+	if info, err := c.daemonInfo(ctx); err == nil && info.NCPU > 0 {
+		return uint32(info.NCPU)
+	}
+	return uint32(runtime.NumCPU())
 }
 
-func (c *DockerCollector) memoryMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, cName string) {
+func (c *DockerCollector) networkMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, l *containerLabels) {
+	// no interfaces with host networking
+	if len(containerStats.Networks) == 0 {
+		return
+	}
+
+	var total types.NetworkStats
+	for _, network := range containerStats.Networks {
+		total.RxBytes += network.RxBytes
+		total.TxBytes += network.TxBytes
+		total.RxPackets += network.RxPackets
+		total.TxPackets += network.TxPackets
+		total.RxErrors += network.RxErrors
+		total.TxErrors += network.TxErrors
+		total.RxDropped += network.RxDropped
+		total.TxDropped += network.TxDropped
+	}
+
+	ch <- l.counter(networkRxBytesTotalDesc, float64(total.RxBytes), l.values...)
+	ch <- l.counter(networkTxBytesTotalDesc, float64(total.TxBytes), l.values...)
+	ch <- l.counter(networkRxPacketsTotalDesc, float64(total.RxPackets), l.values...)
+	ch <- l.counter(networkTxPacketsTotalDesc, float64(total.TxPackets), l.values...)
+	ch <- l.counter(networkRxErrorsTotalDesc, float64(total.RxErrors), l.values...)
+	ch <- l.counter(networkTxErrorsTotalDesc, float64(total.TxErrors), l.values...)
+	ch <- l.counter(networkRxDroppedTotalDesc, float64(total.RxDropped), l.values...)
+	ch <- l.counter(networkTxDroppedTotalDesc, float64(total.TxDropped), l.values...)
+
+	for name, network := range containerStats.Networks {
+		ch <- l.counter(networkInterfaceRxBytesTotalDesc, float64(network.RxBytes), l.valuesWith(name)...)
+		ch <- l.counter(networkInterfaceTxBytesTotalDesc, float64(network.TxBytes), l.valuesWith(name)...)
+	}
+}
+
+func (c *DockerCollector) memoryMetrics(ctx context.Context, ch chan<- prometheus.Metric, containerStats *types.StatsJSON, l *containerLabels) {
 	// From official documentation
 	//Note: On Linux, the Docker CLI reports memory usage by subtracting page cache usage from the total memory usage.
 	//The API does not perform such a calculation but rather provides the total memory usage and the amount from the page cache so that clients can use the data as needed.
-	memoryUsage := containerStats.MemoryStats.Usage - containerStats.MemoryStats.Stats["cache"]
-	memoryTotal := containerStats.MemoryStats.Limit
+	stats := containerStats.MemoryStats.Stats
+	memory := containerMemory(containerStats)
+	memoryTotal, limitSet := c.memoryLimit(ctx, containerStats.MemoryStats.Limit)
+	version := memory.cgroupVersion
+	log.WithField("container", l.name()).Debugf("memory stats of cgroup v%d (0 unknown), limit set: %t", version, limitSet)
 
-	memoryUtilization := float64(memoryUsage) / float64(memoryTotal) * 100.0
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_memory_usage_bytes",
-		"Total memory usage bytes",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(memoryUsage), cName)
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_memory_total_bytes",
-		"Total memory bytes",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(memoryTotal), cName)
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_memory_utilization_percent",
-		"Memory utilization percent",
-		labelCname,
-		nil,
-	), prometheus.GaugeValue, memoryUtilization, cName)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryUsageBytesDesc), prometheus.GaugeValue, float64(memory.usage), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryTotalBytesDesc), prometheus.GaugeValue, float64(memoryTotal), l.values...)
+	// no limit and no host memory, e.g. when the daemon info isn't available
+	if memoryTotal != 0 {
+		memoryUtilization := float64(memory.usage) / float64(memoryTotal) * 100.0
+		ch <- prometheus.MustNewConstMetric(l.desc(memoryUtilizationPercentDesc), prometheus.GaugeValue, memoryUtilization, l.values...)
+	}
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryLimitSetDesc), prometheus.GaugeValue, boolToFloat(limitSet), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryWorkingSetBytesDesc), prometheus.GaugeValue, float64(memory.workingSet), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryCgroupVersionDesc), prometheus.GaugeValue, float64(version), l.values...)
+	if version != 0 {
+		ch <- prometheus.MustNewConstMetric(l.desc(memoryCacheBytesDesc), prometheus.GaugeValue, float64(memory.cache), l.values...)
+	}
+
+	if version == 1 {
+		ch <- l.counter(memoryFailcntTotalDesc, float64(containerStats.MemoryStats.Failcnt), l.values...)
+	}
+	if oomKills, ok := stats["oom_kill"]; ok {
+		ch <- l.counter(memoryOOMEventsTotalDesc, float64(oomKills), l.values...)
+	}
+}
+
+// memoryValues are the memory values dex derives from the stats of a Linux
+// container.
+type memoryValues struct {
+	usage         uint64
+	workingSet    uint64
+	cache         uint64
+	cgroupVersion int
 }
 
-func (c *DockerCollector) blockIoMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, cName string) {
+func containerMemory(containerStats *types.StatsJSON) memoryValues {
+	stats := containerStats.MemoryStats.Stats
+	memory := memoryValues{
+		usage:         subtractBytes(containerStats.MemoryStats.Usage, stats["cache"]),
+		cgroupVersion: memoryCgroupVersion(stats),
+	}
+
+	// cgroup v1 reports hierarchical totals with a total_ prefix, cgroup v2 has
+	// no such keys. Without either, e.g. with unusual cgroup setups, there's
+	// nothing to subtract and the raw usage is reported.
+	var inactiveFile uint64
+	switch memory.cgroupVersion {
+	case 1:
+		memory.cache, inactiveFile = stats["cache"], stats["total_inactive_file"]
+	case 2:
+		memory.cache, inactiveFile = stats["file"], stats["inactive_file"]
+	}
+	memory.workingSet = subtractBytes(containerStats.MemoryStats.Usage, inactiveFile)
+
+	return memory
+}
+
+// memoryCgroupVersion detects the cgroup version from the keys of the memory
+// stats, 0 if there are none of either version.
+func memoryCgroupVersion(stats map[string]uint64) int {
+	if _, ok := stats["total_inactive_file"]; ok {
+		return 1
+	}
+	if _, ok := stats["inactive_file"]; ok {
+		return 2
+	}
+	return 0
+}
+
+// memoryLimit returns the memory limit of a container and whether it's set.
+// Without a limit the daemon reports the host's memory or a page aligned
+// max int64 (cgroup v1), the host's memory from the daemon info is used then.
+func (c *DockerCollector) memoryLimit(ctx context.Context, limit uint64) (uint64, bool) {
+	info, err := c.daemonInfo(ctx)
+	if err != nil || info.MemTotal <= 0 {
+		return limit, limit != 0 && limit < unlimitedMemory
+	}
+
+	hostMemory := uint64(info.MemTotal)
+	if limit == 0 || limit >= hostMemory {
+		return hostMemory, false
+	}
+	return limit, true
+}
+
+// subtractBytes returns a - b, or 0 if b is larger.
+func subtractBytes(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+func (c *DockerCollector) blockIoMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, l *containerLabels) {
 	var readTotal, writeTotal uint64
+	readDevice := map[string]uint64{}
+	writeDevice := map[string]uint64{}
 	for _, b := range containerStats.BlkioStats.IoServiceBytesRecursive {
+		device := c.devices.name(b.Major, b.Minor)
 		if strings.EqualFold(b.Op, "read") {
 			readTotal += b.Value
+			readDevice[device] += b.Value
 		}
 		if strings.EqualFold(b.Op, "write") {
 			writeTotal += b.Value
+			writeDevice[device] += b.Value
 		}
 	}
 
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_block_io_read_bytes",
-		"Block I/O read bytes",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(readTotal), cName)
+	ch <- l.counter(blockIOReadBytesTotalDesc, float64(readTotal), l.values...)
 
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_block_io_write_bytes",
-		"Block I/O write bytes",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(writeTotal), cName)
+	ch <- l.counter(blockIOWriteBytesTotalDesc, float64(writeTotal), l.values...)
+
+	for device, value := range readDevice {
+		ch <- l.counter(blockIODeviceReadBytesTotalDesc, float64(value), l.valuesWith(device)...)
+	}
+
+	for device, value := range writeDevice {
+		ch <- l.counter(blockIODeviceWriteBytesTotalDesc, float64(value), l.valuesWith(device)...)
+	}
+
+	// not reported on all cgroup v2 hosts, missing values would look like idle devices
+	if len(containerStats.BlkioStats.IoServicedRecursive) == 0 {
+		return
+	}
+
+	var reads, writes uint64
+	for _, b := range containerStats.BlkioStats.IoServicedRecursive {
+		if strings.EqualFold(b.Op, "read") {
+			reads += b.Value
+		}
+		if strings.EqualFold(b.Op, "write") {
+			writes += b.Value
+		}
+	}
+
+	ch <- l.counter(blockIOReadsTotalDesc, float64(reads), l.values...)
+
+	ch <- l.counter(blockIOWritesTotalDesc, float64(writes), l.values...)
 }
 
-func (c *DockerCollector) pidsMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, cName string) {
-	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(
-		"dex_pids_current",
-		"Current number of pids in the cgroup",
-		labelCname,
-		nil,
-	), prometheus.CounterValue, float64(containerStats.PidsStats.Current), cName)
+func (c *DockerCollector) pidsMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, l *containerLabels) {
+	ch <- prometheus.MustNewConstMetric(l.desc(pidsCurrentDesc), prometheus.GaugeValue, float64(containerStats.PidsStats.Current), l.values...)
+
+	// 0 if unlimited
+	if containerStats.PidsStats.Limit == 0 {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(pidsLimitDesc), prometheus.GaugeValue, float64(containerStats.PidsStats.Limit), l.values...)
 }
@@ -0,0 +1,817 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// fakeDocker is a dockerAPI serving fixed containers, stats and inspect
+// results.
+type fakeDocker struct {
+	containers []types.Container
+	// stats JSON per container ID
+	stats map[string]string
+	// errors of the stats calls per container ID
+	statsErrors map[string]error
+	inspects    map[string]types.ContainerJSON
+	info        system.Info
+	infoErr     error
+	// Info blocks until its context is done
+	infoHangs bool
+	images    map[string]types.ImageInspect
+	imageErr  error
+	// delay of the image inspect calls
+	imageDelay time.Duration
+
+	mu          sync.Mutex
+	listOptions []container.ListOptions
+	statsCalls  int
+	infoCalls   int
+	imageCalls  int
+}
+
+func (f *fakeDocker) ContainerList(_ context.Context, options container.ListOptions) ([]types.Container, error) {
+	f.mu.Lock()
+	f.listOptions = append(f.listOptions, options)
+	f.mu.Unlock()
+
+	var containers []types.Container
+	for _, ctr := range f.containers {
+		if options.All || ctr.State == "running" {
+			containers = append(containers, ctr)
+		}
+	}
+	return containers, nil
+}
+
+func (f *fakeDocker) ContainerStats(_ context.Context, containerID string, _ bool) (types.ContainerStats, error) {
+	f.mu.Lock()
+	f.statsCalls++
+	f.mu.Unlock()
+
+	if err := f.statsErrors[containerID]; err != nil {
+		return types.ContainerStats{}, err
+	}
+	stats, ok := f.stats[containerID]
+	if !ok {
+		return types.ContainerStats{}, errdefs.NotFound(fmt.Errorf("no such container: %s", containerID))
+	}
+	return types.ContainerStats{Body: io.NopCloser(strings.NewReader(stats))}, nil
+}
+
+func (f *fakeDocker) ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error) {
+	return f.ContainerStats(ctx, containerID, false)
+}
+
+func (f *fakeDocker) ContainerInspect(_ context.Context, containerID string) (types.ContainerJSON, error) {
+	info, ok := f.inspects[containerID]
+	if !ok {
+		return info, errdefs.NotFound(fmt.Errorf("no such container: %s", containerID))
+	}
+	return info, nil
+}
+
+func (f *fakeDocker) ImageInspectWithRaw(_ context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	f.mu.Lock()
+	f.imageCalls++
+	f.mu.Unlock()
+
+	time.Sleep(f.imageDelay)
+	if f.imageErr != nil {
+		return types.ImageInspect{}, nil, f.imageErr
+	}
+	image, ok := f.images[imageID]
+	if !ok {
+		return image, nil, errdefs.NotFound(fmt.Errorf("no such image: %s", imageID))
+	}
+	return image, nil, nil
+}
+
+func (f *fakeDocker) Info(ctx context.Context) (system.Info, error) {
+	f.mu.Lock()
+	f.infoCalls++
+	f.mu.Unlock()
+
+	if f.infoHangs {
+		<-ctx.Done()
+		return system.Info{}, ctx.Err()
+	}
+	return f.info, f.infoErr
+}
+
+// testConfig returns the configuration of the flag defaults relevant to the
+// docker collector.
+func testConfig() *config {
+	return &config{
+		namespace:         "dex",
+		dockerTimeout:     time.Second,
+		containersAll:     true,
+		labelsContainerID: "none",
+	}
+}
+
+// metrics holds the collected metrics by name.
+type metrics map[string][]*dto.Metric
+
+// collectMetrics runs collect and returns the metrics it sent.
+func collectMetrics(t *testing.T, collect func(ch chan<- prometheus.Metric)) metrics {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collect(ch)
+		close(ch)
+	}()
+
+	m := metrics{}
+	for metric := range ch {
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			t.Errorf("can't write %s: %v", metricName(metric), err)
+			continue
+		}
+		m[metricName(metric)] = append(m[metricName(metric)], &pb)
+	}
+	return m
+}
+
+// process runs processContainer for a single container.
+func process(t *testing.T, c *DockerCollector, ctr types.Container) metrics {
+	t.Helper()
+
+	return collectMetrics(t, func(ch chan<- prometheus.Metric) {
+		mapping := c.labelMapping()
+		descs := c.descriptors(mapping)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		c.processContainer(context.Background(), ctr, ch, newHostAggregate(descs), mapping, descs, time.Time{}, &wg)
+	})
+}
+
+// get returns the value of the metric name whose labels include labels, given
+// as name, value pairs.
+func (m metrics) get(name string, labels ...string) (float64, bool) {
+	for _, metric := range m[name] {
+		if !hasLabels(metric, labels) {
+			continue
+		}
+		switch {
+		case metric.Gauge != nil:
+			return metric.Gauge.GetValue(), true
+		case metric.Counter != nil:
+			return metric.Counter.GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+func hasLabels(metric *dto.Metric, labels []string) bool {
+	for i := 0; i+1 < len(labels); i += 2 {
+		found := false
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == labels[i] && pair.GetValue() == labels[i+1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// counterValue returns the current value of a counter.
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+
+	var pb dto.Metric
+	if err := c.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	return pb.GetCounter().GetValue()
+}
+
+const (
+	// stats of a cgroup v1 container with a memory limit
+	statsCgroupV1 = `{
+		"memory_stats": {"usage": 1000, "limit": 4000, "stats": {"cache": 300, "total_inactive_file": 200}},
+		"pids_stats": {"current": 3}
+	}`
+	// stats of a cgroup v2 container with a memory limit
+	statsCgroupV2 = `{
+		"memory_stats": {"usage": 1000, "limit": 4000, "stats": {"file": 300, "inactive_file": 200}},
+		"pids_stats": {"current": 3}
+	}`
+)
+
+func TestProcessContainer(t *testing.T) {
+	running := types.Container{ID: "a1", Names: []string{"/web"}, State: "running"}
+
+	tests := []struct {
+		name      string
+		container types.Container
+		stats     string
+		statsErr  error
+		// daemon info, the default has 8000 bytes of memory
+		info   *system.Info
+		want   map[string]float64
+		absent []string
+	}{
+		{
+			name:      "stopped",
+			container: types.Container{ID: "a1", Names: []string{"/web"}, State: "exited"},
+			want: map[string]float64{
+				"dex_container_running": 0,
+			},
+			absent: []string{"dex_memory_usage_bytes", "dex_container_scrape_success"},
+		},
+		{
+			name:      "cgroup v1",
+			container: running,
+			stats:     statsCgroupV1,
+			want: map[string]float64{
+				"dex_container_running":          1,
+				"dex_container_scrape_success":   1,
+				"dex_memory_usage_bytes":         700,
+				"dex_memory_working_set_bytes":   800,
+				"dex_memory_cache_bytes":         300,
+				"dex_memory_cgroup_version":      1,
+				"dex_memory_total_bytes":         4000,
+				"dex_memory_limit_set":           1,
+				"dex_memory_utilization_percent": 17.5,
+				"dex_pids_current":               3,
+			},
+		},
+		{
+			name:      "cgroup v2",
+			container: running,
+			stats:     statsCgroupV2,
+			want: map[string]float64{
+				"dex_container_running":          1,
+				"dex_container_scrape_success":   1,
+				"dex_memory_usage_bytes":         1000,
+				"dex_memory_working_set_bytes":   800,
+				"dex_memory_cache_bytes":         300,
+				"dex_memory_cgroup_version":      2,
+				"dex_memory_total_bytes":         4000,
+				"dex_memory_utilization_percent": 25,
+			},
+			absent: []string{"dex_memory_failcnt_total"},
+		},
+		{
+			name:      "no memory limit",
+			container: running,
+			stats:     `{"memory_stats": {"usage": 1000, "stats": {"file": 300, "inactive_file": 200}}}`,
+			info:      &system.Info{},
+			want: map[string]float64{
+				"dex_memory_usage_bytes": 1000,
+				"dex_memory_total_bytes": 0,
+				"dex_memory_limit_set":   0,
+			},
+			absent: []string{"dex_memory_utilization_percent"},
+		},
+		{
+			name:      "stats error",
+			container: running,
+			statsErr:  errors.New("daemon error"),
+			want: map[string]float64{
+				"dex_container_running":        1,
+				"dex_container_scrape_success": 0,
+			},
+			absent: []string{"dex_memory_usage_bytes", "dex_pids_current"},
+		},
+		{
+			name:      "malformed stats",
+			container: running,
+			stats:     `{"memory_stats":`,
+			want: map[string]float64{
+				"dex_container_scrape_success": 0,
+			},
+			absent: []string{"dex_memory_usage_bytes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := system.Info{MemTotal: 8000}
+			if tt.info != nil {
+				info = *tt.info
+			}
+			cli := &fakeDocker{
+				containers:  []types.Container{tt.container},
+				stats:       map[string]string{tt.container.ID: tt.stats},
+				statsErrors: map[string]error{tt.container.ID: tt.statsErr},
+				info:        info,
+			}
+			c := newDockerCollector(testConfig(), cli)
+
+			m := process(t, c, tt.container)
+
+			for name, want := range tt.want {
+				got, ok := m.get(name, "container_name", "web")
+				if !ok {
+					t.Errorf("%s missing", name)
+				} else if got != want {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, ok := m.get(name); ok {
+					t.Errorf("%s reported, want none", name)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchStatsErrors(t *testing.T) {
+	ctr := types.Container{ID: "a1", Names: []string{"/web"}, State: "running"}
+
+	tests := []struct {
+		name        string
+		stats       string
+		statsErr    error
+		wantErr     bool
+		wantErrors  float64
+		wantBackoff bool
+	}{
+		{
+			name:  "success",
+			stats: statsCgroupV2,
+		},
+		{
+			name:        "daemon error",
+			statsErr:    errors.New("daemon error"),
+			wantErr:     true,
+			wantErrors:  1,
+			wantBackoff: true,
+		},
+		{
+			name:        "malformed stats",
+			stats:       `{"memory_stats":`,
+			wantErr:     true,
+			wantErrors:  1,
+			wantBackoff: true,
+		},
+		{
+			name:        "removed container",
+			statsErr:    errdefs.NotFound(errors.New("no such container: a1")),
+			wantErr:     true,
+			wantErrors:  1,
+			wantBackoff: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeDocker{
+				stats:       map[string]string{ctr.ID: tt.stats},
+				statsErrors: map[string]error{ctr.ID: tt.statsErr},
+			}
+			cfg := testConfig()
+			cfg.statsFailureThreshold = 1
+			cfg.statsFailureBackoff = time.Minute
+			c := newDockerCollector(cfg, cli)
+
+			_, err := c.fetchStats(context.Background(), ctr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchStats() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got := counterValue(t, c.scrapeErrors.WithLabelValues("container_stats", "web")); got != tt.wantErrors {
+				t.Errorf("scrape errors = %v, want %v", got, tt.wantErrors)
+			}
+			if got := c.backoff.skip(ctr.ID); got != tt.wantBackoff {
+				t.Errorf("backoff = %t, want %t", got, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestCollectRemovedContainer(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	// gone is removed between the list and the stats call, the daemon
+	// answers its stats with a 404
+	cli := &fakeDocker{
+		containers: []types.Container{
+			{ID: "a1", Names: []string{"/web"}, State: "running"},
+			{ID: "b2", Names: []string{"/gone"}, State: "running"},
+		},
+		stats: map[string]string{"a1": statsCgroupV2},
+	}
+	c := newDockerCollector(testConfig(), cli)
+
+	for i := 0; i < 2; i++ {
+		m := collectMetrics(t, c.Collect)
+		if got, ok := m.get("dex_memory_usage_bytes", "container_name", "web"); !ok || got != 1000 {
+			t.Errorf("memory usage of web = %v, %t, want 1000", got, ok)
+		}
+	}
+
+	// counted on every scrape, logged once
+	if got := counterValue(t, c.scrapeErrors.WithLabelValues("container_stats", "gone")); got != 2 {
+		t.Errorf("scrape errors of gone = %v, want 2", got)
+	}
+	if got := errorLogs(hook, "can't get api stats"); got != 1 {
+		t.Errorf("%d stats errors logged, want 1", got)
+	}
+}
+
+func TestCPUUtilization(t *testing.T) {
+	cpuStats := func(total, system uint64, cpus uint32) types.CPUStats {
+		return types.CPUStats{
+			CPUUsage:    types.CPUUsage{TotalUsage: total},
+			SystemUsage: system,
+			OnlineCPUs:  cpus,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		cpu      types.CPUStats
+		preCPU   types.CPUStats
+		hostCPUs uint32
+		want     float64
+		wantOK   bool
+	}{
+		{
+			name:   "two samples",
+			cpu:    cpuStats(3000, 20000, 4),
+			preCPU: cpuStats(1000, 10000, 4),
+			want:   80,
+			wantOK: true,
+		},
+		{
+			name:   "first sample",
+			cpu:    cpuStats(3000, 20000, 4),
+			preCPU: types.CPUStats{},
+		},
+		{
+			name:   "zero system delta",
+			cpu:    cpuStats(3000, 10000, 4),
+			preCPU: cpuStats(1000, 10000, 4),
+		},
+		{
+			name:   "counter reset",
+			cpu:    cpuStats(500, 20000, 4),
+			preCPU: cpuStats(1000, 10000, 4),
+		},
+		{
+			name: "per CPU usage without online CPUs",
+			cpu: types.CPUStats{
+				CPUUsage:    types.CPUUsage{TotalUsage: 3000, PercpuUsage: []uint64{1500, 1500}},
+				SystemUsage: 20000,
+			},
+			preCPU: cpuStats(1000, 10000, 0),
+			want:   40,
+			wantOK: true,
+		},
+		{
+			// cgroup v2 has no per CPU usage
+			name:     "cgroup v2 without online CPUs",
+			cpu:      cpuStats(3000, 20000, 0),
+			preCPU:   cpuStats(1000, 10000, 0),
+			hostCPUs: 8,
+			want:     160,
+			wantOK:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &types.StatsJSON{Stats: types.Stats{CPUStats: tt.cpu, PreCPUStats: tt.preCPU}}
+			got, ok := cpuUtilization(stats, tt.hostCPUs)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("cpuUtilization() = %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestContainerMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage uint64
+		stats map[string]uint64
+		want  memoryValues
+	}{
+		{
+			name:  "cgroup v1",
+			usage: 1000,
+			stats: map[string]uint64{"cache": 300, "total_inactive_file": 200, "inactive_file": 100},
+			want:  memoryValues{usage: 700, workingSet: 800, cache: 300, cgroupVersion: 1},
+		},
+		{
+			name:  "cgroup v2",
+			usage: 1000,
+			stats: map[string]uint64{"file": 300, "inactive_file": 200},
+			want:  memoryValues{usage: 1000, workingSet: 800, cache: 300, cgroupVersion: 2},
+		},
+		{
+			name:  "cache larger than usage",
+			usage: 100,
+			stats: map[string]uint64{"cache": 300, "total_inactive_file": 200},
+			want:  memoryValues{usage: 0, workingSet: 0, cache: 300, cgroupVersion: 1},
+		},
+		{
+			name:  "unknown",
+			usage: 1000,
+			stats: map[string]uint64{"rss": 600},
+			want:  memoryValues{usage: 1000, workingSet: 1000},
+		},
+		{
+			name:  "no stats",
+			usage: 1000,
+			want:  memoryValues{usage: 1000, workingSet: 1000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &types.StatsJSON{Stats: types.Stats{MemoryStats: types.MemoryStats{Usage: tt.usage, Stats: tt.stats}}}
+			if got := containerMemory(stats); got != tt.want {
+				t.Errorf("containerMemory() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectContainersAll(t *testing.T) {
+	containers := []types.Container{
+		{ID: "a1", Names: []string{"/web"}, State: "running"},
+		{ID: "b2", Names: []string{"/job"}, State: "exited"},
+	}
+
+	tests := []struct {
+		all  bool
+		want map[string]float64
+	}{
+		{
+			all:  true,
+			want: map[string]float64{"web": 1, "job": 0},
+		},
+		{
+			all:  false,
+			want: map[string]float64{"web": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("all=%t", tt.all), func(t *testing.T) {
+			cli := &fakeDocker{
+				containers: containers,
+				stats:      map[string]string{"a1": statsCgroupV2},
+			}
+			cfg := testConfig()
+			cfg.containersAll = tt.all
+			c := newDockerCollector(cfg, cli)
+
+			m := collectMetrics(t, c.Collect)
+
+			if len(cli.listOptions) == 0 {
+				t.Fatal("containers not listed")
+			}
+			for _, options := range cli.listOptions {
+				if options.All != tt.all {
+					t.Errorf("listed with All = %t, want %t", options.All, tt.all)
+				}
+			}
+			if got := len(m["dex_container_running"]); got != len(tt.want) {
+				t.Errorf("dex_container_running of %d containers, want %d", got, len(tt.want))
+			}
+			for name, want := range tt.want {
+				if got, ok := m.get("dex_container_running", "container_name", name); !ok || got != want {
+					t.Errorf("dex_container_running of %s = %v, %t, want %v", name, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+// blockingDocker is a fakeDocker whose stats calls take a while, it records
+// the most calls in flight at once.
+type blockingDocker struct {
+	*fakeDocker
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (b *blockingDocker) ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error) {
+	b.mu.Lock()
+	b.inFlight++
+	b.maxInFlight = max(b.maxInFlight, b.inFlight)
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	return b.fakeDocker.ContainerStatsOneShot(ctx, containerID)
+}
+
+func TestStatsMaxConcurrent(t *testing.T) {
+	const limit = 3
+
+	fake := &fakeDocker{stats: map[string]string{}}
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("c%d", i)
+		fake.containers = append(fake.containers, types.Container{ID: id, Names: []string{"/" + id}, State: "running"})
+		fake.stats[id] = statsCgroupV2
+	}
+	cli := &blockingDocker{fakeDocker: fake}
+
+	cfg := testConfig()
+	cfg.statsMaxConcurrent = limit
+	cfg.dockerTimeout = 10 * time.Second
+	c := newDockerCollector(cfg, cli)
+
+	m := collectMetrics(t, c.Collect)
+
+	if got := len(m["dex_memory_usage_bytes"]); got != len(fake.containers) {
+		t.Errorf("memory usage of %d containers, want %d", got, len(fake.containers))
+	}
+	if cli.maxInFlight != limit {
+		t.Errorf("%d stats calls in flight at most, want %d", cli.maxInFlight, limit)
+	}
+}
+
+func TestCollectComposeLabels(t *testing.T) {
+	cli := &fakeDocker{
+		containers: []types.Container{
+			{ID: "a1", Names: []string{"/shop-web-1"}, State: "running", Labels: map[string]string{
+				composeProjectLabel: "shop",
+				composeServiceLabel: "web",
+			}},
+			{ID: "b2", Names: []string{"/standalone"}, State: "running"},
+		},
+		stats: map[string]string{"a1": statsCgroupV2, "b2": statsCgroupV2},
+	}
+	cfg := testConfig()
+	cfg.labelsCompose = true
+	c := newDockerCollector(cfg, cli)
+
+	// the registry rejects metrics of one name with different label names
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := collectMetrics(t, c.Collect)
+	for _, labels := range [][]string{
+		{"container_name", "shop-web-1", "compose_project", "shop", "compose_service", "web"},
+		{"container_name", "standalone", "compose_project", "", "compose_service", ""},
+	} {
+		if _, ok := m.get("dex_memory_usage_bytes", labels...); !ok {
+			t.Errorf("no memory usage with labels %q", labels)
+		}
+	}
+}
+
+func TestDaemonInfoFailure(t *testing.T) {
+	cli := &fakeDocker{
+		containers: []types.Container{
+			{ID: "a1", Names: []string{"/web"}, State: "running"},
+			{ID: "b2", Names: []string{"/db"}, State: "running"},
+			{ID: "c3", Names: []string{"/cache"}, State: "running"},
+		},
+		stats:   map[string]string{"a1": statsCgroupV2, "b2": statsCgroupV2, "c3": statsCgroupV2},
+		infoErr: errors.New("daemon error"),
+	}
+	c := newDockerCollector(testConfig(), cli)
+
+	m := collectMetrics(t, c.Collect)
+
+	// the memory limit of every container needs the daemon info
+	if got := cli.infoCalls; got != 1 {
+		t.Errorf("%d info calls, want 1", got)
+	}
+	if got, ok := m.get("dex_memory_total_bytes", "container_name", "web"); !ok || got != 4000 {
+		t.Errorf("dex_memory_total_bytes = %v, %t, want the container's limit 4000", got, ok)
+	}
+
+	// the failure is cached until the retry interval passed
+	c.infoFailed = time.Now().Add(-infoRetryInterval)
+	cli.infoErr = nil
+	cli.info = system.Info{MemTotal: 8000}
+	if info, err := c.daemonInfo(context.Background()); err != nil || info.MemTotal != 8000 {
+		t.Errorf("daemonInfo() after the retry interval = %v, %v, want MemTotal 8000", info.MemTotal, err)
+	}
+}
+
+func TestDaemonInfoTimeout(t *testing.T) {
+	cli := &fakeDocker{infoHangs: true}
+	c := newDockerCollector(testConfig(), cli)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.daemonInfo(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("daemonInfo() error = %v, want the deadline", err)
+	}
+
+	// the timed out scrape isn't cached as a failure of the daemon
+	cli.infoHangs = false
+	cli.info = system.Info{MemTotal: 8000}
+	if _, err := c.daemonInfo(context.Background()); err != nil {
+		t.Errorf("daemonInfo() after a timed out call: %v", err)
+	}
+}
+
+func TestSecurityProfiles(t *testing.T) {
+	containers := []types.Container{
+		{ID: "a1", Names: []string{"/web"}, State: "exited"},
+		{ID: "b2", Names: []string{"/db"}, State: "exited"},
+	}
+	inspects := map[string]types.ContainerJSON{}
+	for _, ctr := range containers {
+		inspects[ctr.ID] = types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: ctr.ID, Name: ctr.Names[0], HostConfig: &container.HostConfig{}},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		info        system.Info
+		infoErr     error
+		wantSeccomp string
+	}{
+		{
+			name:        "daemon info",
+			info:        system.Info{SecurityOptions: []string{"name=seccomp,profile=builtin"}},
+			wantSeccomp: "default",
+		},
+		{
+			name:        "daemon info failed",
+			infoErr:     errors.New("daemon error"),
+			wantSeccomp: "disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeDocker{containers: containers, inspects: inspects, info: tt.info, infoErr: tt.infoErr}
+			c := newDockerCollector(testConfig(), cli)
+
+			m := collectMetrics(t, c.Collect)
+
+			for _, ctr := range containers {
+				name := containerName(ctr)
+				if _, ok := m.get("dex_container_security_info", "container_name", name, "seccomp", tt.wantSeccomp); !ok {
+					t.Errorf("no security info of %s with seccomp %s", name, tt.wantSeccomp)
+				}
+			}
+			// the default profiles of all containers come from the cached info
+			if cli.infoCalls != 1 {
+				t.Errorf("%d info calls, want 1", cli.infoCalls)
+			}
+		})
+	}
+}
+
+func TestOneShotStatsCPUUtilization(t *testing.T) {
+	ctr := types.Container{ID: "a1", Names: []string{"/web"}, State: "running"}
+	cli := &fakeDocker{
+		stats: map[string]string{ctr.ID: `{"cpu_stats": {"cpu_usage": {"total_usage": 1000}, "system_cpu_usage": 10000, "online_cpus": 4}}`},
+	}
+	c := newDockerCollector(testConfig(), cli)
+
+	// the one-shot stats have no previous sample
+	first, err := c.fetchStats(context.Background(), ctr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cpuUtilization(first, 0); ok {
+		t.Error("CPU utilization of the first sample, want none")
+	}
+
+	// the previous one is the sample of the last scrape
+	cli.stats[ctr.ID] = `{"cpu_stats": {"cpu_usage": {"total_usage": 3000}, "system_cpu_usage": 20000, "online_cpus": 4}}`
+	second, err := c.fetchStats(context.Background(), ctr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cpuUtilization(second, 0); !ok || got != 80 {
+		t.Errorf("CPU utilization of the second sample = %v, %t, want 80", got, ok)
+	}
+}
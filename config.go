@@ -0,0 +1,529 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// valid metric name prefixes, colons are reserved for recording rules
+var namespacePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type config struct {
+	version bool
+	// dex inspect <container>
+	inspect bool
+
+	configFile  string
+	configCheck bool
+
+	logLevel  string
+	logFormat string
+	// parsed --log.level
+	logLevelValue log.Level
+
+	dockerHosts      stringList
+	endpoints        []dockerEndpoint
+	dockerTLSCA      string
+	dockerTLSCert    string
+	dockerTLSKey     string
+	dockerAPIVersion string
+	dockerTimeout    time.Duration
+
+	writableLayer         bool
+	writableLayerInterval time.Duration
+	writableLayerBudget   time.Duration
+
+	size         bool
+	sizeInterval time.Duration
+
+	diskUsage         bool
+	diskUsageInterval time.Duration
+
+	mountUsage                 bool
+	mountUsageInterval         time.Duration
+	mountUsageBudget           time.Duration
+	mountUsageIncludeNetworkFS bool
+
+	tmpfs       bool
+	devices     bool
+	gpu         bool
+	connections bool
+	pressure    bool
+	percpu      bool
+
+	portProbe        bool
+	portProbeAddress string
+	portProbeTimeout time.Duration
+	portProbeBudget  time.Duration
+
+	imageLabels bool
+
+	swarm bool
+
+	stateDuration bool
+
+	events    bool
+	eventsTTL time.Duration
+
+	stateFile     string
+	stateInterval time.Duration
+
+	dockerMetricsURL        string
+	dockerMetricsAllow      string
+	dockerMetricsAllowRegex *regexp.Regexp
+
+	aggregateOnly  bool
+	hostAggregates bool
+
+	maxContainers int
+
+	normalizeSwarmNames bool
+
+	networks stringList
+
+	containersAll     bool
+	containersInclude string
+	containersExclude string
+	containersLabels  stringList
+
+	containersAbsentGrace time.Duration
+
+	legacyNames bool
+
+	metricsOnly      string
+	metricsOnlyRegex *regexp.Regexp
+
+	labelsMapFile string
+
+	labelsDocker string
+	dockerLabels []dockerLabel
+
+	labelsSwarm   bool
+	labelsCompose bool
+
+	labelsContainerID string
+
+	webListenAddress string
+	webMetricsPath   string
+	webLogRequests   bool
+	webSoftFail      bool
+	webTLSCert       string
+	webTLSKey        string
+	webAuthUsers     string
+
+	webDebugEndpoints bool
+
+	namespace string
+
+	statsSource     string
+	statsCgroupRoot string
+	statsMode       string
+
+	statsMaxConcurrent int
+	statsInterval      time.Duration
+
+	statsFailureThreshold int
+	statsFailureBackoff   time.Duration
+
+	otlpEndpoint string
+	otlpInterval time.Duration
+
+	remoteWriteURL             string
+	remoteWriteInterval        time.Duration
+	remoteWriteUsername        string
+	remoteWritePassword        string
+	remoteWriteBearerTokenFile string
+
+	graphiteAddress  string
+	graphitePrefix   string
+	graphiteInterval time.Duration
+	graphiteTags     bool
+
+	textfilePath     string
+	textfileInterval time.Duration
+
+	pushgatewayURL      string
+	pushgatewayJob      string
+	pushgatewayGrouping stringList
+	pushgatewayInterval time.Duration
+}
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseConfig parses the command line and the config file given with
+// --config.file, flags on the command line take precedence.
+func parseConfig() (*config, error) {
+	cfg := &config{}
+
+	registerFlags(flag.CommandLine, cfg)
+	if err := parseCommandLine(flag.CommandLine, cfg, os.Args[1:]); err != nil {
+		return nil, err
+	}
+
+	if cfg.configFile != "" {
+		if err := loadConfigFile(cfg.configFile, flag.CommandLine); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseCommandLine parses the flags and the subcommand of args, the flags may
+// be given before and after the subcommand.
+func parseCommandLine(flags *flag.FlagSet, cfg *config, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.Arg(0) == "inspect" {
+		cfg.inspect = true
+		return flags.Parse(flags.Args()[1:])
+	}
+	return nil
+}
+
+// registerFlags registers the command line flags setting cfg on flags.
+func registerFlags(flags *flag.FlagSet, cfg *config) {
+	flags.BoolVar(&cfg.version, "version", false,
+		"Print the version, git revision, build date and Go version and exit")
+	flags.StringVar(&cfg.configFile, "config.file", "",
+		"YAML file setting any of the other options, keyed by flag name (e.g. docker: {timeout: 5s}), flags override it")
+	flags.BoolVar(&cfg.configCheck, "config.check", false,
+		"Validate the configuration and exit with 0 if it's valid and 1 otherwise")
+
+	flags.StringVar(&cfg.logLevel, "log.level", "info",
+		"Minimum level of log messages: debug, info, warn or error")
+	flags.StringVar(&cfg.logFormat, "log.format", "text",
+		"Format of log messages: text or json")
+	flags.DurationVar(&cfg.dockerTimeout, "docker.timeout", 10*time.Second,
+		"Maximum time the docker API calls of a scrape may take, outstanding calls are cancelled afterwards")
+	flags.Var(&cfg.dockerHosts, "docker.host",
+		"Docker daemon to connect to, e.g. unix:///var/run/docker.sock, tcp://host:2376 or npipe:////./pipe/docker_engine, optionally prefixed with alias= for the host label (repeatable, default DOCKER_HOST or the platform's default)")
+	flags.StringVar(&cfg.dockerTLSCA, "docker.tls-ca", "",
+		"PEM CA certificate file to verify the docker daemon with (default DOCKER_CERT_PATH/ca.pem if DOCKER_TLS_VERIFY is set)")
+	flags.StringVar(&cfg.dockerTLSCert, "docker.tls-cert", "",
+		"PEM client certificate file to authenticate to the docker daemon with (needs --docker.tls-key)")
+	flags.StringVar(&cfg.dockerTLSKey, "docker.tls-key", "",
+		"PEM private key file of --docker.tls-cert")
+	flags.StringVar(&cfg.dockerAPIVersion, "docker.api-version", "",
+		"Docker API version to use, e.g. 1.43 (default DOCKER_API_VERSION or negotiated with the daemon)")
+
+	flags.BoolVar(&cfg.writableLayer, "collector.writable-layer", false,
+		"Enable the writable layer disk usage collector (needs access to the docker data root)")
+	flags.DurationVar(&cfg.writableLayerInterval, "collector.writable-layer.interval", 5*time.Minute,
+		"Refresh interval of the writable layer disk usage")
+	flags.DurationVar(&cfg.writableLayerBudget, "collector.writable-layer.budget", 10*time.Second,
+		"Maximum time spent walking the writable layer of a single container")
+	flags.BoolVar(&cfg.size, "collector.size", false,
+		"Enable the writable layer and root filesystem size metrics computed by the docker daemon (expensive on hosts with many containers)")
+	flags.DurationVar(&cfg.sizeInterval, "collector.size.interval", 5*time.Minute,
+		"Refresh interval of the container sizes")
+	flags.BoolVar(&cfg.diskUsage, "collector.disk-usage", false,
+		"Enable the volume, image and build cache disk usage metrics of docker system df")
+	flags.DurationVar(&cfg.diskUsageInterval, "collector.disk-usage.interval", 5*time.Minute,
+		"Refresh interval of the docker system df metrics")
+	flags.BoolVar(&cfg.mountUsage, "collector.mount-usage", false,
+		"Enable the bind mount and volume disk usage collector (needs access to the mount sources)")
+	flags.DurationVar(&cfg.mountUsageInterval, "collector.mount-usage.interval", 5*time.Minute,
+		"Refresh interval of the mount disk usage")
+	flags.DurationVar(&cfg.mountUsageBudget, "collector.mount-usage.budget", 10*time.Second,
+		"Maximum time spent walking the mounts of a single container")
+	flags.BoolVar(&cfg.mountUsageIncludeNetworkFS, "collector.mount-usage.include-network-fs", false,
+		"Also walk mounts located on network filesystems and volumes of non-local drivers")
+	flags.BoolVar(&cfg.tmpfs, "collector.tmpfs", false,
+		"Enable tmpfs mount limit and usage metrics (usage needs access to the host's /proc)")
+	flags.BoolVar(&cfg.aggregateOnly, "metrics.aggregate-only", false,
+		"Only expose host-level aggregates, no per-container metrics")
+	flags.BoolVar(&cfg.hostAggregates, "metrics.host-aggregates", false,
+		"Expose the host-level aggregates of --metrics.aggregate-only in addition to the per-container metrics")
+	flags.IntVar(&cfg.maxContainers, "containers.max", 0,
+		"Maximum number of containers to collect, the newest are kept (0 means unlimited)")
+	flags.Var(&cfg.networks, "containers.network",
+		"Only collect containers attached to this docker network (repeatable, any match selects the container)")
+	flags.BoolVar(&cfg.containersAll, "containers.all", true,
+		"Collect stopped containers too, only running containers are collected if false")
+	flags.StringVar(&cfg.containersInclude, "containers.include", "",
+		"Only collect containers whose name matches this regular expression")
+	flags.StringVar(&cfg.containersExclude, "containers.exclude", "",
+		"Don't collect containers whose name matches this regular expression, applied after --containers.include")
+	flags.Var(&cfg.containersLabels, "containers.label",
+		"Only collect containers with this docker label, key or key=value (repeatable, all have to match)")
+	flags.DurationVar(&cfg.containersAbsentGrace, "containers.absent-grace", 0,
+		"Keep reporting removed containers as not running for this long after they were last listed (0 disables)")
+	flags.BoolVar(&cfg.normalizeSwarmNames, "containers.normalize-swarm-names", false,
+		"Strip the task ID from the names of swarm task containers (myservice.3.<task id> becomes myservice.3)")
+	flags.BoolVar(&cfg.legacyNames, "metrics.legacy-names", false,
+		"Additionally expose byte counters under their names without _total and memory and pids gauges as counters, like older releases")
+	flags.StringVar(&cfg.metricsOnly, "metrics.only", "",
+		"Comma-separated list of metric names or regular expressions to expose, all other metrics are dropped")
+	flags.StringVar(&cfg.labelsMapFile, "labels.map-file", "",
+		"YAML or JSON file mapping container names or docker labels to additional static labels, re-read on SIGHUP")
+	flags.StringVar(&cfg.labelsDocker, "labels.docker", "",
+		"Comma-separated list of docker labels of the containers to add to all per-container metrics, e.g. com.docker.compose.service")
+	flags.BoolVar(&cfg.labelsSwarm, "labels.swarm", false,
+		"Add the service_name and task_slot labels to the metrics of swarm task containers and strip the task ID from their names (implies --containers.normalize-swarm-names)")
+	flags.BoolVar(&cfg.labelsCompose, "labels.compose", false,
+		"Add the compose_project and compose_service labels to the metrics of containers created by docker compose")
+	flags.StringVar(&cfg.labelsContainerID, "labels.container-id", "none",
+		"Add the container ID as container_id label to all per-container metrics: none, short (12 characters) or full")
+	// DEX_PORT is the way to change the port of older releases
+	listenAddress := ":8080"
+	if port, isSet := os.LookupEnv("DEX_PORT"); isSet {
+		listenAddress = ":" + port
+	}
+	flags.StringVar(&cfg.webListenAddress, "web.listen-address", listenAddress,
+		"Address to listen on for the metrics endpoint, no HTTP server is started if empty (needs a push output or --textfile.path)")
+	flags.StringVar(&cfg.webMetricsPath, "web.metrics-path", "/metrics",
+		"Path of the metrics endpoint")
+	flags.StringVar(&cfg.namespace, "metrics.namespace", "dex",
+		"Prefix of the names of all exposed metrics")
+	flags.BoolVar(&cfg.webLogRequests, "web.log-requests", false,
+		"Log every HTTP request at info level (they are logged at debug level otherwise)")
+	flags.BoolVar(&cfg.webSoftFail, "web.soft-fail", false,
+		"Respond with 200 even if the docker daemon is not reachable (503 otherwise)")
+	flags.StringVar(&cfg.webTLSCert, "web.tls-cert", "",
+		"PEM certificate file to serve HTTPS with, re-read on SIGHUP (needs --web.tls-key)")
+	flags.StringVar(&cfg.webTLSKey, "web.tls-key", "",
+		"PEM private key file of --web.tls-cert")
+	flags.StringVar(&cfg.webAuthUsers, "web.auth-users", "",
+		"htpasswd file with bcrypt hashed passwords (htpasswd -B), all requests need basic auth if set")
+	flags.BoolVar(&cfg.webDebugEndpoints, "web.debug-endpoints", false,
+		"Serve the raw and derived stats of all containers as JSON at /debug/containers")
+	flags.StringVar(&cfg.statsSource, "stats.source", "docker",
+		"Source of the container stats: docker (stats API) or cgroupfs (read the cgroup files directly, needs the host's cgroup filesystem)")
+	flags.StringVar(&cfg.statsCgroupRoot, "stats.cgroup-root", "/sys/fs/cgroup",
+		"Mount point of the host's cgroup filesystem for --stats.source=cgroupfs")
+	flags.StringVar(&cfg.statsMode, "stats.mode", "oneshot",
+		"How the container stats are read from the docker API: oneshot (a request per container and scrape) or stream (a persistent stats stream per running container, scrapes serve the latest sample)")
+	flags.IntVar(&cfg.statsMaxConcurrent, "stats.max-concurrent", 8,
+		"Maximum number of concurrent stats calls to the docker daemon during a scrape (0 means unlimited)")
+	flags.DurationVar(&cfg.statsInterval, "stats.interval", 0,
+		"Refresh the container stats in the background at this interval and serve the cached stats on scrapes (0 reads them on every scrape)")
+	flags.IntVar(&cfg.statsFailureThreshold, "stats.failure-threshold", 3,
+		"Number of consecutive stats failures after which a container's stats are skipped (0 disables)")
+	flags.DurationVar(&cfg.statsFailureBackoff, "stats.failure-backoff", 10*time.Minute,
+		"Time a container's stats are skipped after repeated failures before retrying")
+	flags.BoolVar(&cfg.devices, "collector.devices", false,
+		"Enable the info metric of host devices mapped into containers")
+	flags.BoolVar(&cfg.gpu, "collector.gpu", false,
+		"Enable NVIDIA GPU metrics of containers with reserved GPUs (needs a build with -tags nvml)")
+	flags.BoolVar(&cfg.connections, "collector.connections", false,
+		"Enable TCP and UDP socket counts of containers (needs the host's PID namespace)")
+	flags.BoolVar(&cfg.pressure, "collector.pressure", false,
+		"Enable the CPU, memory and I/O pressure stall metrics of containers (needs the host's cgroup v2 filesystem at --stats.cgroup-root)")
+	flags.BoolVar(&cfg.portProbe, "collector.port-probe", false,
+		"Enable TCP connect probes of the published ports of containers labeled dex.probe=true")
+	flags.StringVar(&cfg.portProbeAddress, "collector.port-probe.address", "127.0.0.1",
+		"Address to probe ports published on all interfaces at")
+	flags.DurationVar(&cfg.portProbeTimeout, "collector.port-probe.timeout", time.Second,
+		"Maximum time a single port probe may take")
+	flags.DurationVar(&cfg.portProbeBudget, "collector.port-probe.budget", 5*time.Second,
+		"Maximum time spent probing the ports of all containers during a scrape")
+	flags.BoolVar(&cfg.percpu, "collector.percpu", false,
+		"Enable per-core CPU usage metrics (cgroup v1 only, one series per core and container)")
+	flags.BoolVar(&cfg.imageLabels, "collector.image-labels", false,
+		"Add the OCI version, revision and source labels of the image to dex_container_info")
+	flags.StringVar(&cfg.otlpEndpoint, "otlp.endpoint", "",
+		"OTLP/HTTP endpoint URL to push metrics to, e.g. http://otel-collector:4318 (disabled if empty)")
+	flags.DurationVar(&cfg.otlpInterval, "otlp.interval", time.Minute,
+		"Interval of the OTLP metrics export")
+	flags.StringVar(&cfg.remoteWriteURL, "remote-write.url", "",
+		"Prometheus remote write endpoint to push metrics to (disabled if empty)")
+	flags.DurationVar(&cfg.remoteWriteInterval, "remote-write.interval", 30*time.Second,
+		"Interval of the remote write push")
+	flags.StringVar(&cfg.remoteWriteUsername, "remote-write.username", "",
+		"Basic auth username for remote write")
+	flags.StringVar(&cfg.remoteWritePassword, "remote-write.password", "",
+		"Basic auth password for remote write")
+	flags.StringVar(&cfg.remoteWriteBearerTokenFile, "remote-write.bearer-token-file", "",
+		"File containing a bearer token for remote write")
+	flags.StringVar(&cfg.graphiteAddress, "graphite.address", "",
+		"Carbon endpoint (host:port) to push metrics to (disabled if empty)")
+	flags.StringVar(&cfg.graphitePrefix, "graphite.prefix", "dex",
+		"Prefix of the metric paths pushed to Graphite")
+	flags.DurationVar(&cfg.graphiteInterval, "graphite.interval", time.Minute,
+		"Interval of the Graphite push")
+	flags.BoolVar(&cfg.graphiteTags, "graphite.tags", false,
+		"Push labels as Graphite tags instead of flattening them into the metric path")
+	flags.StringVar(&cfg.textfilePath, "textfile.path", "",
+		"File to write the metrics to in the text format, e.g. for the node exporter's textfile collector (disabled if empty)")
+	flags.DurationVar(&cfg.textfileInterval, "textfile.interval", time.Minute,
+		"Interval of the textfile write")
+	flags.StringVar(&cfg.pushgatewayURL, "pushgateway.url", "",
+		"Pushgateway URL to push metrics to (disabled if empty)")
+	flags.StringVar(&cfg.pushgatewayJob, "pushgateway.job", "dex",
+		"Job name of the pushed metrics")
+	flags.Var(&cfg.pushgatewayGrouping, "pushgateway.grouping",
+		"Additional grouping key of the pushed metrics as name=value, e.g. instance=host1 (repeatable)")
+	flags.DurationVar(&cfg.pushgatewayInterval, "pushgateway.interval", time.Minute,
+		"Interval of the Pushgateway push")
+	flags.BoolVar(&cfg.swarm, "collector.swarm", false,
+		"Enable swarm service metrics (only reported on manager nodes)")
+	flags.BoolVar(&cfg.stateDuration, "collector.state-duration", false,
+		"Enable tracking the time containers spend in each state from the docker events stream")
+	flags.BoolVar(&cfg.events, "collector.events", false,
+		"Enable counting start, die, oom and restart events of containers from the docker events stream")
+	flags.DurationVar(&cfg.eventsTTL, "collector.events.ttl", time.Hour,
+		"Time the event counters of removed containers are kept")
+	flags.StringVar(&cfg.stateFile, "state.file", "",
+		"File to checkpoint the event-derived counters to, they are restored from it on startup (disabled if empty)")
+	flags.DurationVar(&cfg.stateInterval, "state.interval", time.Minute,
+		"Interval of the state file checkpoints")
+	flags.StringVar(&cfg.dockerMetricsURL, "docker.metrics-url", "",
+		"URL of the docker daemon's metrics endpoint to re-export, e.g. http://127.0.0.1:9323/metrics (disabled if empty)")
+	flags.StringVar(&cfg.dockerMetricsAllow, "docker.metrics-allow", "engine_daemon_.*,builder_.*,swarm_.*",
+		"Comma-separated list of daemon metric names or regular expressions to re-export")
+}
+
+// validate rejects contradicting options.
+func (cfg *config) validate() error {
+	if cfg.aggregateOnly && (cfg.writableLayer || cfg.size || cfg.mountUsage || cfg.tmpfs || cfg.devices || cfg.gpu || cfg.connections || cfg.pressure || cfg.percpu || cfg.portProbe || cfg.imageLabels || cfg.stateDuration || cfg.events || cfg.labelsMapFile != "" || cfg.labelsDocker != "" || cfg.labelsSwarm || cfg.labelsCompose || cfg.labelsContainerID != "none" || cfg.containersAbsentGrace > 0) {
+		return errors.New("--metrics.aggregate-only can't be combined with per-container collectors or labels")
+	}
+
+	if cfg.webListenAddress == "" && cfg.textfilePath == "" && cfg.pushgatewayURL == "" && cfg.remoteWriteURL == "" && cfg.graphiteAddress == "" && cfg.otlpEndpoint == "" {
+		return errors.New("an empty --web.listen-address needs --textfile.path, --pushgateway.url, --remote-write.url, --graphite.address or --otlp.endpoint")
+	}
+
+	for _, pair := range cfg.pushgatewayGrouping {
+		name, _, ok := strings.Cut(pair, "=")
+		if !ok || !model.LabelName(name).IsValid() || name == "job" {
+			return fmt.Errorf("invalid --pushgateway.grouping %q, must be name=value with a label name other than job", pair)
+		}
+	}
+
+	level, err := log.ParseLevel(cfg.logLevel)
+	if err != nil || level > log.DebugLevel || level < log.ErrorLevel {
+		return fmt.Errorf("invalid --log.level %q, must be debug, info, warn or error", cfg.logLevel)
+	}
+	cfg.logLevelValue = level
+
+	if cfg.logFormat != "text" && cfg.logFormat != "json" {
+		return fmt.Errorf("invalid --log.format %q, must be text or json", cfg.logFormat)
+	}
+
+	if !namespacePattern.MatchString(cfg.namespace) {
+		return fmt.Errorf("invalid --metrics.namespace %q, must start with a letter or underscore followed by letters, digits and underscores", cfg.namespace)
+	}
+
+	if !strings.HasPrefix(cfg.webMetricsPath, "/") {
+		return fmt.Errorf("invalid --web.metrics-path %q, must start with /", cfg.webMetricsPath)
+	}
+
+	if (cfg.dockerTLSCert == "") != (cfg.dockerTLSKey == "") {
+		return errors.New("--docker.tls-cert and --docker.tls-key have to be given together")
+	}
+
+	if (cfg.webTLSCert == "") != (cfg.webTLSKey == "") {
+		return errors.New("--web.tls-cert and --web.tls-key have to be given together")
+	}
+
+	endpoints, err := parseEndpoints(cfg)
+	if err != nil {
+		return err
+	}
+	cfg.endpoints = endpoints
+
+	// these read the local filesystem, which only belongs to one of the daemons
+	if len(cfg.endpoints) > 1 && (cfg.writableLayer || cfg.mountUsage || cfg.pressure || cfg.statsSource == "cgroupfs" || cfg.stateFile != "") {
+		return errors.New("several --docker.host can't be combined with --collector.writable-layer, --collector.mount-usage, --collector.pressure, --stats.source=cgroupfs or --state.file")
+	}
+
+	if cfg.statsSource != "docker" && cfg.statsSource != "cgroupfs" {
+		return fmt.Errorf("invalid --stats.source %q, must be docker or cgroupfs", cfg.statsSource)
+	}
+
+	switch cfg.statsMode {
+	case "oneshot":
+	case "stream":
+		if cfg.statsSource == "cgroupfs" || cfg.statsInterval > 0 {
+			return errors.New("--stats.mode=stream can't be combined with --stats.source=cgroupfs or --stats.interval")
+		}
+	default:
+		return fmt.Errorf("invalid --stats.mode %q, must be oneshot or stream", cfg.statsMode)
+	}
+
+	if cfg.stateFile != "" && !cfg.stateDuration && !cfg.events {
+		return errors.New("--state.file needs --collector.state-duration or --collector.events")
+	}
+
+	if cfg.metricsOnly != "" {
+		re, err := compileMetricsOnly(cfg.metricsOnly)
+		if err != nil {
+			return fmt.Errorf("invalid --metrics.only: %w", err)
+		}
+		cfg.metricsOnlyRegex = re
+	}
+
+	if cfg.labelsContainerID != "none" && cfg.labelsContainerID != "short" && cfg.labelsContainerID != "full" {
+		return fmt.Errorf("invalid --labels.container-id %q, must be none, short or full", cfg.labelsContainerID)
+	}
+
+	if cfg.labelsDocker != "" {
+		labels, err := parseDockerLabels(cfg.labelsDocker)
+		if err != nil {
+			return fmt.Errorf("invalid --labels.docker: %w", err)
+		}
+		cfg.dockerLabels = labels
+	}
+	for _, name := range dockerLabelNames(cfg.dockerLabels) {
+		if slices.Contains(cfg.optionalLabelNames(), name) {
+			return fmt.Errorf("invalid --labels.docker: the label name %q is used by dex", name)
+		}
+	}
+
+	// the task ID is stripped from the container names, which needs the
+	// deduplication of tasks of the same slot
+	if cfg.labelsSwarm {
+		cfg.normalizeSwarmNames = true
+	}
+
+	if cfg.dockerMetricsURL != "" {
+		re, err := compileMetricsOnly(cfg.dockerMetricsAllow)
+		if err != nil {
+			return fmt.Errorf("invalid --docker.metrics-allow: %w", err)
+		}
+		cfg.dockerMetricsAllowRegex = re
+	}
+
+	return nil
+}
+
+// optionalLabelNames returns the label names dex adds depending on the
+// configuration, they can't be used by --labels.docker and the label map.
+func (cfg *config) optionalLabelNames() []string {
+	var names []string
+	if hostLabeled(cfg.endpoints) {
+		names = append(names, hostLabel)
+	}
+	if cfg.labelsContainerID != "none" {
+		names = append(names, "container_id")
+	}
+	if cfg.labelsSwarm {
+		names = append(names, swarmLabelNames...)
+	}
+	if cfg.labelsCompose {
+		names = append(names, composeLabelNames...)
+	}
+	return names
+}
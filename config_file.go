@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfigFile sets the flags not given on the command line from a YAML
+// file. The keys are the flag names, nested mappings are joined with dots, so
+// docker.timeout can be written as
+//
+//	docker:
+//	  timeout: 5s
+//
+// Repeatable flags take a list. Unknown keys are rejected with their line.
+func loadConfigFile(file string, flags *flag.FlagSet) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("can't parse %s: %w", file, err)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	// flags on the command line override the file
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	l := &configFileLoader{file: file, flags: flags, given: given, seen: map[string]bool{}}
+	return l.mapping("", root.Content[0])
+}
+
+type configFileLoader struct {
+	file  string
+	flags *flag.FlagSet
+	given map[string]bool
+	seen  map[string]bool
+}
+
+func (l *configFileLoader) mapping(prefix string, node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		if prefix == "" {
+			return l.errorf(node, "expected a mapping of options")
+		}
+		return l.errorf(node, "unknown option %q", prefix)
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		name := key.Value
+		if prefix != "" {
+			name = prefix + "." + key.Value
+		}
+
+		f := l.flags.Lookup(name)
+		if f == nil {
+			if err := l.mapping(name, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "config.file" || name == "config.check" {
+			return l.errorf(key, "%s can only be given on the command line", name)
+		}
+		if l.seen[name] {
+			return l.errorf(key, "option %q is set twice", name)
+		}
+		l.seen[name] = true
+
+		if err := l.set(f, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *configFileLoader) set(f *flag.Flag, node *yaml.Node) error {
+	var values []*yaml.Node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		values = []*yaml.Node{node}
+	case yaml.SequenceNode:
+		if _, ok := f.Value.(*stringList); !ok {
+			return l.errorf(node, "option %q doesn't take a list", f.Name)
+		}
+		values = node.Content
+	default:
+		return l.errorf(node, "option %q needs a value", f.Name)
+	}
+
+	if l.given[f.Name] {
+		return nil
+	}
+
+	for _, value := range values {
+		if value.Kind != yaml.ScalarNode {
+			return l.errorf(value, "option %q needs a value", f.Name)
+		}
+		if err := f.Value.Set(value.Value); err != nil {
+			return l.errorf(value, "invalid value %q for option %q: %v", value.Value, f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (l *configFileLoader) errorf(node *yaml.Node, format string, args ...any) error {
+	return fmt.Errorf("%s line %d: %s", l.file, node.Line, fmt.Sprintf(format, args...))
+}
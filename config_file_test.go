@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFlags registers the command line flags of dex on a new flag set.
+func testFlags(cfg *config) *flag.FlagSet {
+	flags := flag.NewFlagSet("dex", flag.ContinueOnError)
+	registerFlags(flags, cfg)
+	return flags
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	cfg := &config{}
+	flags := testFlags(cfg)
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadConfigFile("testdata/dex.yml", flags); err != nil {
+		t.Fatal(err)
+	}
+
+	// the defaults with the options of the file
+	want := &config{}
+	if err := testFlags(want).Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	want.dockerHosts = stringList{"local=unix:///var/run/docker.sock", "db=tcp://10.0.0.6:2376"}
+	want.dockerTimeout = 20 * time.Second
+	want.containersInclude = "web-.*"
+	want.containersLabels = stringList{"com.example.monitored=true"}
+	want.containersAll = false
+	want.namespace = "dex"
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+}
+
+// TestExampleConfigFile keeps the documented example in sync with the flags.
+func TestExampleConfigFile(t *testing.T) {
+	cfg := &config{}
+	flags := testFlags(cfg)
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadConfigFile("docs/dex.yml", flags); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() = %v", err)
+	}
+
+	if want := (stringList{"local=unix:///var/run/docker.sock", "db=tcp://10.0.0.6:2376"}); !reflect.DeepEqual(cfg.dockerHosts, want) {
+		t.Errorf("docker.host = %q, want %q", cfg.dockerHosts, want)
+	}
+	if !cfg.portProbe || cfg.portProbeTimeout != time.Second {
+		t.Errorf("collector.port-probe = %t with timeout %v, want true with 1s", cfg.portProbe, cfg.portProbeTimeout)
+	}
+}
+
+func TestLoadConfigFileFlagPrecedence(t *testing.T) {
+	cfg := &config{}
+	flags := testFlags(cfg)
+	if err := flags.Parse([]string{"--docker.timeout=3s", "--docker.host=unix:///run/docker.sock", "--containers.all"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadConfigFile("testdata/dex.yml", flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.dockerTimeout != 3*time.Second {
+		t.Errorf("docker.timeout = %v, want the flag's 3s", cfg.dockerTimeout)
+	}
+	if want := (stringList{"unix:///run/docker.sock"}); !reflect.DeepEqual(cfg.dockerHosts, want) {
+		t.Errorf("docker.host = %q, want the flag's %q", cfg.dockerHosts, want)
+	}
+	if !cfg.containersAll {
+		t.Error("containers.all = false, want the flag's true")
+	}
+	// not given on the command line
+	if cfg.containersInclude != "web-.*" {
+		t.Errorf("containers.include = %q, want the file's web-.*", cfg.containersInclude)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{
+			name: "unknown option",
+			file: "docker:\n  timeout: 5s\n  tiemout: 5s\n",
+			want: "line 3: unknown option \"docker.tiemout\"",
+		},
+		{
+			name: "set twice",
+			file: "docker:\n  timeout: 5s\ndocker.timeout: 6s\n",
+			want: "line 3: option \"docker.timeout\" is set twice",
+		},
+		{
+			name: "invalid value",
+			file: "docker.timeout: soon\n",
+			want: "line 1: invalid value \"soon\" for option \"docker.timeout\"",
+		},
+		{
+			name: "list of a single value option",
+			file: "docker.timeout:\n  - 5s\n",
+			want: "line 2: option \"docker.timeout\" doesn't take a list",
+		},
+		{
+			name: "config file option",
+			file: "config.file: other.yml\n",
+			want: "line 1: config.file can only be given on the command line",
+		},
+		{
+			name: "no mapping",
+			file: "- docker.timeout\n",
+			want: "line 1: expected a mapping of options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "dex.yml")
+			if err := os.WriteFile(file, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			flags := testFlags(&config{})
+			if err := flags.Parse(nil); err != nil {
+				t.Fatal(err)
+			}
+			err := loadConfigFile(file, flags)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadConfigFile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
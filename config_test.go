@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		args        []string
+		wantInspect bool
+		wantArgs    []string
+	}{
+		{args: []string{"--docker.timeout=3s"}},
+		{args: []string{"inspect", "--docker.timeout=3s", "web"}, wantInspect: true, wantArgs: []string{"web"}},
+		{args: []string{"--docker.timeout=3s", "inspect", "web"}, wantInspect: true, wantArgs: []string{"web"}},
+	}
+
+	for _, tt := range tests {
+		cfg := &config{}
+		flags := flag.NewFlagSet("dex", flag.ContinueOnError)
+		registerFlags(flags, cfg)
+
+		if err := parseCommandLine(flags, cfg, tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.inspect != tt.wantInspect {
+			t.Errorf("%q: inspect = %t, want %t", tt.args, cfg.inspect, tt.wantInspect)
+		}
+		if cfg.dockerTimeout != 3*time.Second {
+			t.Errorf("%q: docker.timeout = %v, want 3s", tt.args, cfg.dockerTimeout)
+		}
+		if got := flags.Args(); !slices.Equal(got, tt.wantArgs) {
+			t.Errorf("%q: args = %q, want %q", tt.args, got, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync/atomic"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	containerTCPConnectionsDesc = newContainerDesc(
+		"container_tcp_connections",
+		"Number of TCP sockets in the container's network namespace by state",
+		"state",
+	)
+	containerUDPSocketsDesc = newContainerDesc(
+		"container_udp_sockets",
+		"Number of UDP sockets in the container's network namespace",
+	)
+)
+
+// TCP states as encoded in /proc/net/tcp (include/net/tcp_states.h)
+var tcpStates = map[string]string{
+	"01": "established",
+	"02": "syn_sent",
+	"03": "syn_recv",
+	"04": "fin_wait1",
+	"05": "fin_wait2",
+	"06": "time_wait",
+	"07": "close",
+	"08": "close_wait",
+	"09": "last_ack",
+	"0A": "listen",
+	"0B": "closing",
+}
+
+// socketReader counts the sockets of a container's network namespace through
+// the procfs of its init process. It needs the host's /proc, i.e. dex running
+// in the host's PID namespace, and disables itself otherwise.
+type socketReader struct {
+	disabled atomic.Bool
+}
+
+func newSocketReader() (*socketReader, error) {
+	if _, err := os.Stat("/proc/self/net/tcp"); err != nil {
+		return nil, err
+	}
+	return &socketReader{}, nil
+}
+
+func (c *DockerCollector) connectionMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	if c.sockets.disabled.Load() || info.State == nil || info.State.Pid == 0 {
+		return
+	}
+	// host network, the counts would be those of the host
+	if info.HostConfig != nil && info.HostConfig.NetworkMode.IsHost() {
+		return
+	}
+
+	netDir := fmt.Sprintf("/proc/%d/net", info.State.Pid)
+
+	tcp := map[string]int{"established": 0}
+	for _, file := range []string{"tcp", "tcp6"} {
+		err := countSockets(netDir+"/"+file, func(state []byte) {
+			if name, ok := tcpStates[string(state)]; ok {
+				tcp[name]++
+			}
+		})
+		if errors.Is(err, fs.ErrNotExist) && file == "tcp" {
+			// the container's init process isn't visible, dex doesn't see the host's /proc
+			if !c.sockets.disabled.Swap(true) {
+				log.Warn("connection collector disabled, the host's PID namespace isn't available")
+			}
+			return
+		}
+		if err != nil {
+			log.Debugf("can't read sockets of container %s: %v", l.name(), err)
+		}
+	}
+
+	udp := 0
+	for _, file := range []string{"udp", "udp6"} {
+		err := countSockets(netDir+"/"+file, func([]byte) { udp++ })
+		if err != nil {
+			log.Debugf("can't read sockets of container %s: %v", l.name(), err)
+		}
+	}
+
+	for state, count := range tcp {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerTCPConnectionsDesc), prometheus.GaugeValue, float64(count), l.valuesWith(state)...)
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerUDPSocketsDesc), prometheus.GaugeValue, float64(udp), l.values...)
+}
+
+// countSockets calls fn with the hex state of every socket in a /proc/net/{tcp,udp}
+// table. The tables can be large, the lines are parsed without allocating.
+func countSockets(path string, fn func(state []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		line := scanner.Bytes()
+		for i := 0; i < 3; i++ {
+			line = bytes.TrimLeft(line, " ")
+			if n := bytes.IndexByte(line, ' '); n >= 0 {
+				line = line[n:]
+			}
+		}
+		line = bytes.TrimLeft(line, " ")
+		if n := bytes.IndexByte(line, ' '); n >= 0 {
+			fn(line[:n])
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// container events counted by the events collector
+var countedEvents = []events.Action{events.ActionStart, events.ActionDie, events.ActionOOM, events.ActionRestart}
+
+type containerEventCounts struct {
+	name   string
+	counts map[events.Action]float64
+
+	// when the container was removed, zero while it exists
+	removed time.Time
+}
+
+// ContainerEventsCollector counts start, die, oom and restart events of
+// containers from the events of an eventsWatcher, so crashes between two
+// scrapes are visible. The counters are kept by container ID, a container
+// recreated with the same name continues the counters of the removed one.
+// Counters of removed containers are dropped after ttl.
+type ContainerEventsCollector struct {
+	cli  *dockerClient
+	desc *prometheus.Desc
+	ttl  time.Duration
+
+	mu         sync.Mutex
+	containers map[string]*containerEventCounts
+}
+
+func newContainerEventsCollector(cli *dockerClient, namespace string, ttl time.Duration) *ContainerEventsCollector {
+	return &ContainerEventsCollector{
+		cli: cli,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_events_total"),
+			"Number of start, die, oom and restart events of the container since dex started",
+			[]string{"container_name", "event"},
+			nil,
+		),
+		ttl:        ttl,
+		containers: map[string]*containerEventCounts{},
+	}
+}
+
+func (c *ContainerEventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *ContainerEventsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, container := range c.containers {
+		if !container.removed.IsZero() && time.Since(container.removed) > c.ttl {
+			delete(c.containers, id)
+			continue
+		}
+
+		for _, action := range countedEvents {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, container.counts[action], container.name, string(action))
+		}
+	}
+}
+
+func (c *ContainerEventsCollector) handle(msg events.Message) {
+	switch msg.Action {
+	case events.ActionDestroy:
+		c.remove(msg.Actor.ID, time.Now())
+	case events.ActionRename:
+		c.mu.Lock()
+		if _, ok := c.containers[msg.Actor.ID]; ok {
+			c.rename(msg.Actor.ID, msg.Actor.Attributes["name"])
+		}
+		c.mu.Unlock()
+	case events.ActionStart, events.ActionDie, events.ActionOOM, events.ActionRestart:
+		c.count(msg)
+	}
+}
+
+// replay applies an event that happened while dex was down.
+func (c *ContainerEventsCollector) replay(msg events.Message) {
+	switch msg.Action {
+	case events.ActionDestroy:
+		c.remove(msg.Actor.ID, time.Unix(0, msg.TimeNano))
+	case events.ActionStart, events.ActionDie, events.ActionOOM, events.ActionRestart:
+		c.count(msg)
+	}
+}
+
+func (c *ContainerEventsCollector) count(msg events.Message) {
+	name := msg.Actor.Attributes["name"]
+	if name == "" || !c.selects(msg) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	container, ok := c.containers[msg.Actor.ID]
+	if !ok {
+		container = &containerEventCounts{counts: map[events.Action]float64{}}
+		c.containers[msg.Actor.ID] = container
+	}
+	c.rename(msg.Actor.ID, name)
+	container.counts[msg.Action]++
+}
+
+func (c *ContainerEventsCollector) remove(id string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if container, ok := c.containers[id]; ok && container.removed.IsZero() {
+		container.removed = at
+	}
+}
+
+// rename sets the name of a container, taking over the counters of a removed
+// container of the same name so the series continues. The caller must hold
+// the lock.
+func (c *ContainerEventsCollector) rename(id, name string) {
+	container := c.containers[id]
+	container.name = name
+
+	for otherID, other := range c.containers {
+		if otherID == id || other.name != name {
+			continue
+		}
+		for action, count := range other.counts {
+			container.counts[action] += count
+		}
+		delete(c.containers, otherID)
+	}
+}
+
+// selects applies the container filter, the events carry the name and the
+// labels of the container, the networks have to be inspected.
+func (c *ContainerEventsCollector) selects(msg events.Message) bool {
+	var networks map[string]*network.EndpointSettings
+	if c.cli.filter.networks != nil {
+		info, err := c.cli.ContainerInspect(context.Background(), msg.Actor.ID)
+		if err != nil {
+			log.Debug("can't inspect container: ", err)
+			return false
+		}
+		if info.NetworkSettings != nil {
+			networks = info.NetworkSettings.Networks
+		}
+	}
+
+	return c.cli.filter.selects(msg.Actor.Attributes["name"], msg.Actor.Attributes, networks)
+}
+
+// sync starts the TTL of all containers that don't exist anymore, destroy
+// events may have been missed while the stream was interrupted.
+func (c *ContainerEventsCollector) sync(containers []types.Container, at time.Time) {
+	names := map[string]string{}
+	for _, container := range containers {
+		names[container.ID] = containerName(container)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, container := range c.containers {
+		name, ok := names[id]
+		if !ok {
+			if container.removed.IsZero() {
+				container.removed = at
+			}
+			continue
+		}
+		if container.name != name {
+			c.rename(id, name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+func containerEvent(action events.Action, id, name string) events.Message {
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: map[string]string{"name": name}},
+		TimeNano: time.Now().UnixNano(),
+	}
+}
+
+func TestContainerEventsRecreated(t *testing.T) {
+	c := newContainerEventsCollector(&dockerClient{filter: &containerFilter{}}, "dex", time.Hour)
+
+	c.handle(containerEvent(events.ActionStart, "old", "web"))
+	c.handle(containerEvent(events.ActionDie, "old", "web"))
+	c.handle(containerEvent(events.ActionDestroy, "old", "web"))
+	c.handle(containerEvent(events.ActionStart, "new", "web"))
+
+	if _, ok := c.containers["old"]; ok {
+		t.Error("counters of the removed container are kept next to the recreated one")
+	}
+	counts := c.containers["new"].counts
+	if counts[events.ActionStart] != 2 || counts[events.ActionDie] != 1 {
+		t.Errorf("counts of the recreated container = %v, want 2 starts and 1 die", counts)
+	}
+
+	// renamed while the stream was interrupted
+	c.sync([]types.Container{{ID: "new", Names: []string{"/web-1"}}}, time.Now())
+	if name := c.containers["new"].name; name != "web-1" {
+		t.Errorf("name after sync = %q, want web-1", name)
+	}
+}
+
+func TestStateFileEvents(t *testing.T) {
+	cli := &dockerClient{filter: &containerFilter{}}
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	saved := &stateFile{path: path, events: newContainerEventsCollector(cli, "dex", time.Hour)}
+	saved.events.handle(containerEvent(events.ActionStart, "abc", "web"))
+	saved.events.handle(containerEvent(events.ActionOOM, "abc", "web"))
+	if err := saved.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := readCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container, ok := cp.Events["abc"]
+	if !ok {
+		t.Fatalf("checkpoint %+v has no event counters", cp)
+	}
+	if container.Name != "web" || container.Counts["start"] != 1 || container.Counts["oom"] != 1 {
+		t.Errorf("checkpointed counters = %+v, want 1 start and 1 oom of web", container)
+	}
+}
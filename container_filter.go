@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+)
+
+// containerFilter selects the containers dex collects. The label filter is
+// passed to the daemon, names and networks are matched by dex.
+type containerFilter struct {
+	// only containers attached to one of these networks are selected if set
+	networks map[string]bool
+
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+
+	// key or key=value, all have to match
+	labels []string
+}
+
+func newContainerFilter(cfg *config) (*containerFilter, error) {
+	f := &containerFilter{}
+
+	if len(cfg.networks) > 0 {
+		f.networks = map[string]bool{}
+		for _, name := range cfg.networks {
+			f.networks[name] = true
+		}
+	}
+
+	var err error
+	if f.include, err = compileNamePattern(cfg.containersInclude); err != nil {
+		return nil, fmt.Errorf("invalid --containers.include: %w", err)
+	}
+	if f.exclude, err = compileNamePattern(cfg.containersExclude); err != nil {
+		return nil, fmt.Errorf("invalid --containers.exclude: %w", err)
+	}
+
+	for _, label := range cfg.containersLabels {
+		if key, _, _ := strings.Cut(label, "="); key == "" {
+			return nil, fmt.Errorf("invalid --containers.label %q, must be key or key=value", label)
+		}
+		f.labels = append(f.labels, label)
+	}
+
+	return f, nil
+}
+
+// compileNamePattern compiles a regular expression matching whole container names.
+func compileNamePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// listOptions adds the label filter to the options of a container list call.
+func (f *containerFilter) listOptions(options container.ListOptions) container.ListOptions {
+	if len(f.labels) == 0 {
+		return options
+	}
+
+	args := filters.NewArgs()
+	for _, key := range options.Filters.Keys() {
+		for _, value := range options.Filters.Get(key) {
+			args.Add(key, value)
+		}
+	}
+	for _, label := range f.labels {
+		args.Add("label", label)
+	}
+	options.Filters = args
+	return options
+}
+
+// selects reports whether a container is collected. Containers have to match
+// --containers.include if set, --containers.exclude is applied afterwards and
+// takes precedence.
+func (f *containerFilter) selects(name string, labels map[string]string, networks map[string]*network.EndpointSettings) bool {
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+
+	// the daemon already filtered the listed containers by label, the check
+	// is for containers looked up otherwise, e.g. from events
+	for _, label := range f.labels {
+		key, value, hasValue := strings.Cut(label, "=")
+		if actual, ok := labels[key]; !ok || hasValue && actual != value {
+			return false
+		}
+	}
+
+	if f.networks == nil {
+		return true
+	}
+	for name := range networks {
+		if f.networks[name] {
+			return true
+		}
+	}
+	return false
+}
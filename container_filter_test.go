@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+func TestContainerFilterSelects(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  string
+		exclude  string
+		labels   []string
+		networks []string
+		want     map[string]bool
+	}{
+		{
+			name: "no filter",
+			want: map[string]bool{"web": true, "web-debug": true, "db": true},
+		},
+		{
+			name:    "include only",
+			include: "web.*",
+			want:    map[string]bool{"web": true, "web-debug": true, "db": false},
+		},
+		{
+			name:    "exclude only",
+			exclude: ".*-debug",
+			want:    map[string]bool{"web": true, "web-debug": false, "db": true},
+		},
+		{
+			name:    "both matching",
+			include: "web.*",
+			exclude: ".*-debug",
+			want:    map[string]bool{"web": true, "web-debug": false, "db": false},
+		},
+		{
+			name:    "whole names",
+			include: "web",
+			want:    map[string]bool{"web": true, "web-debug": false, "db": false},
+		},
+		{
+			name:   "label",
+			labels: []string{"tier=frontend"},
+			want:   map[string]bool{"web": true, "web-debug": true, "db": false},
+		},
+		{
+			name:     "network",
+			networks: []string{"backend"},
+			want:     map[string]bool{"web": false, "web-debug": false, "db": true},
+		},
+	}
+
+	labels := map[string]map[string]string{
+		"web":       {"tier": "frontend"},
+		"web-debug": {"tier": "frontend"},
+		"db":        {"tier": "backend"},
+	}
+	networks := map[string]map[string]*network.EndpointSettings{
+		"web":       {"frontend": {}},
+		"web-debug": {"frontend": {}},
+		"db":        {"backend": {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newContainerFilter(&config{
+				containersInclude: tt.include,
+				containersExclude: tt.exclude,
+				containersLabels:  tt.labels,
+				networks:          tt.networks,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tt.want {
+				if got := f.selects(name, labels[name], networks[name]); got != want {
+					t.Errorf("selects(%q) = %t, want %t", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewContainerFilterInvalid(t *testing.T) {
+	for _, cfg := range []*config{
+		{containersInclude: "web("},
+		{containersExclude: "[a-"},
+		{containersLabels: []string{"=frontend"}},
+	} {
+		if _, err := newContainerFilter(cfg); err == nil {
+			t.Errorf("newContainerFilter(%+v) succeeded", cfg)
+		}
+	}
+}
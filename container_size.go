@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// containerSize is the disk usage of a container as reported by the daemon.
+type containerSize struct {
+	rw     int64
+	rootFS int64
+}
+
+// ContainerSizeCollector reports the size of the writable layer and of the
+// whole root filesystem of containers. The daemon computes them by walking
+// the layers, which is slow on hosts with many containers, so they are
+// fetched in the background on a slow interval and the last result is
+// served on scrape.
+type ContainerSizeCollector struct {
+	cli        *dockerClient
+	rwDesc     *prometheus.Desc
+	rootFSDesc *prometheus.Desc
+	interval   time.Duration
+	all        bool
+
+	mu    sync.Mutex
+	sizes map[string]containerSize
+}
+
+func newContainerSizeCollector(cli *dockerClient, namespace string, interval time.Duration, all bool) *ContainerSizeCollector {
+	c := &ContainerSizeCollector{
+		cli: cli,
+		rwDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_rw_size_bytes"),
+			"Size of the files created or changed in the container's writable layer in bytes",
+			labelCname,
+			nil,
+		),
+		rootFSDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_rootfs_size_bytes"),
+			"Size of all files of the container's root filesystem including the image in bytes",
+			labelCname,
+			nil,
+		),
+		interval: interval,
+		all:      all,
+		sizes:    map[string]containerSize{},
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *ContainerSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.rwDesc
+	ch <- c.rootFSDesc
+}
+
+func (c *ContainerSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for cName, size := range c.sizes {
+		ch <- prometheus.MustNewConstMetric(c.rwDesc, prometheus.GaugeValue, float64(size.rw), cName)
+		ch <- prometheus.MustNewConstMetric(c.rootFSDesc, prometheus.GaugeValue, float64(size.rootFS), cName)
+	}
+}
+
+func (c *ContainerSizeCollector) run() {
+	for {
+		start := time.Now()
+		c.refresh()
+		log.Debugf("container sizes refreshed in %v", time.Since(start))
+
+		time.Sleep(c.interval)
+	}
+}
+
+func (c *ContainerSizeCollector) refresh() {
+	containers, err := c.cli.ContainerList(context.Background(), container.ListOptions{All: c.all, Size: true})
+	if err != nil {
+		log.Error("can't list container sizes: ", err)
+		return
+	}
+
+	sizes := map[string]containerSize{}
+	for _, container := range containers {
+		sizes[containerName(container)] = containerSize{rw: container.SizeRw, rootFS: container.SizeRootFs}
+	}
+
+	c.mu.Lock()
+	c.sizes = sizes
+	c.mu.Unlock()
+}
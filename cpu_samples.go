@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+type prevCPUSample struct {
+	read  time.Time
+	stats types.CPUStats
+}
+
+// cpuSamples keeps the previous CPU sample of every container for the stats
+// sources without one, the daemon's one-shot stats and the cgroup filesystem.
+// The previous sample is filled in as PreCPUStats, so the CPU utilization is
+// computed between two scrapes.
+type cpuSamples struct {
+	mu       sync.Mutex
+	previous map[string]prevCPUSample
+}
+
+func newCPUSamples() *cpuSamples {
+	return &cpuSamples{previous: map[string]prevCPUSample{}}
+}
+
+// fill sets the previous sample of the container as PreCPUStats of stats and
+// remembers stats for the next one. The first sample of a container has none.
+func (s *cpuSamples) fill(id string, stats *types.StatsJSON) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prev, ok := s.previous[id]; ok {
+		stats.PreRead = prev.read
+		stats.PreCPUStats = prev.stats
+	}
+	s.previous[id] = prevCPUSample{read: stats.Read, stats: stats.CPUStats}
+}
+
+// peek sets the previous sample of the container as PreCPUStats of stats like
+// fill without remembering stats, for reads outside of scrapes.
+func (s *cpuSamples) peek(id string, stats *types.StatsJSON) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prev, ok := s.previous[id]; ok {
+		stats.PreRead = prev.read
+		stats.PreCPUStats = prev.stats
+	}
+}
+
+// prune drops the previous samples of all containers not in ids.
+func (s *cpuSamples) prune(ids map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.previous {
+		if !ids[id] {
+			delete(s.previous, id)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	log "github.com/sirupsen/logrus"
+)
+
+// debugEndpoint is a docker daemon served by /debug/containers.
+type debugEndpoint struct {
+	alias     string
+	collector *DockerCollector
+}
+
+// debugContainer is a container in the response of /debug/containers.
+type debugContainer struct {
+	Host  string `json:"host,omitempty"`
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	State string `json:"state"`
+
+	Error   string           `json:"error,omitempty"`
+	Stats   *types.StatsJSON `json:"stats,omitempty"`
+	Derived *debugDerived    `json:"derived,omitempty"`
+}
+
+// debugDerived are the values dex computes from the stats for its metrics.
+type debugDerived struct {
+	// 1 or 2, 0 if unknown or Windows
+	CgroupVersion         int      `json:"cgroup_version"`
+	MemoryUsageBytes      uint64   `json:"memory_usage_bytes"`
+	MemoryWorkingSetBytes uint64   `json:"memory_working_set_bytes"`
+	MemoryCacheBytes      uint64   `json:"memory_cache_bytes"`
+	CPUUtilizationPercent *float64 `json:"cpu_utilization_percent,omitempty"`
+	BlockIOReadBytes      uint64   `json:"block_io_read_bytes"`
+	BlockIOWriteBytes     uint64   `json:"block_io_write_bytes"`
+}
+
+// debugContainersHandler responds with the raw stats of all running containers
+// and the values derived from them as JSON, for debugging. Stopped containers
+// are listed without stats.
+func debugContainersHandler(endpoints []debugEndpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		containers := []debugContainer{}
+		for _, endpoint := range endpoints {
+			list, err := endpoint.collector.debugContainers(r.Context())
+			if err != nil {
+				http.Error(w, "can't list containers: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			for i := range list {
+				list[i].Host = endpoint.alias
+			}
+			containers = append(containers, list...)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(containers); err != nil {
+			log.Debug("can't write debug response: ", err)
+		}
+	})
+}
+
+// debugContainers reads the stats of the containers of a daemon like a scrape.
+func (c *DockerCollector) debugContainers(ctx context.Context) ([]debugContainer, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.dockerTimeout)
+	defer cancel()
+
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: c.cfg.containersAll})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]debugContainer, len(containers))
+	var wg sync.WaitGroup
+	for i, ctr := range containers {
+		result[i] = debugContainer{Name: containerName(ctr), ID: ctr.ID, State: ctr.State}
+		if ctr.State != "running" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(d *debugContainer, ctr types.Container) {
+			defer wg.Done()
+
+			stats, err := c.debugStats(ctx, ctr)
+			if err != nil {
+				d.Error = err.Error()
+				return
+			}
+			d.Stats = stats
+			d.Derived = deriveStats(stats, c.hostCPUs(ctx))
+		}(&result[i], ctr)
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// debugStats reads the stats of a container like a scrape without its
+// bookkeeping: failures count neither as scrape errors nor towards the
+// backoff, and the next scrape keeps its previous CPU sample.
+func (c *DockerCollector) debugStats(ctx context.Context, container types.Container) (*types.StatsJSON, error) {
+	if c.statsCache != nil {
+		return c.statsCache.get(container.ID)
+	}
+	if c.statsStreams != nil {
+		return c.statsStreams.get(container.ID)
+	}
+
+	if cgroups := c.cgroups.get(ctx, c.daemonInfo); cgroups != nil {
+		info, err := c.inspects.get(ctx, container)
+		if err != nil {
+			return nil, err
+		}
+		stats, err := cgroups.read(&info)
+		if err != nil {
+			return nil, err
+		}
+		cgroups.samples.peek(container.ID, stats)
+		return stats, nil
+	}
+
+	stats, err := c.readAPIStats(ctx, container.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.cpuSamples.peek(container.ID, stats)
+	return stats, nil
+}
+
+func deriveStats(stats *types.StatsJSON, hostCPUs uint32) *debugDerived {
+	var derived debugDerived
+	if isWindowsStats(stats) {
+		derived.MemoryUsageBytes = stats.MemoryStats.PrivateWorkingSet
+		derived.MemoryWorkingSetBytes = stats.MemoryStats.PrivateWorkingSet
+	} else {
+		memory := containerMemory(stats)
+		derived.CgroupVersion = memory.cgroupVersion
+		derived.MemoryUsageBytes = memory.usage
+		derived.MemoryWorkingSetBytes = memory.workingSet
+		derived.MemoryCacheBytes = memory.cache
+	}
+
+	if utilization, ok := cpuUtilization(stats, hostCPUs); ok {
+		derived.CPUUtilizationPercent = &utilization
+	}
+
+	for _, b := range stats.BlkioStats.IoServiceBytesRecursive {
+		if strings.EqualFold(b.Op, "read") {
+			derived.BlockIOReadBytes += b.Value
+		}
+		if strings.EqualFold(b.Op, "write") {
+			derived.BlockIOWriteBytes += b.Value
+		}
+	}
+
+	return &derived
+}
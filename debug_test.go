@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestDebugContainersScrapeAccounting(t *testing.T) {
+	cli := &fakeDocker{
+		containers: []types.Container{
+			{ID: "a1", Names: []string{"/web"}, State: "running"},
+			{ID: "b2", Names: []string{"/gone"}, State: "running"},
+		},
+		stats: map[string]string{"a1": statsCgroupV2},
+	}
+	c := newDockerCollector(testConfig(), cli)
+
+	for i := 0; i < 5; i++ {
+		result, err := c.debugContainers(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result[1].Error == "" {
+			t.Errorf("stats of gone = %+v, want an error", result[1])
+		}
+	}
+
+	// the failures of the debug requests don't show up in the scrapes
+	if got := counterValue(t, c.scrapeErrors.WithLabelValues("container_stats", "gone")); got != 0 {
+		t.Errorf("scrape errors of gone = %v, want 0", got)
+	}
+	if c.backoff.skip("b2") {
+		t.Error("stats of gone are backed off")
+	}
+	if len(c.cpuSamples.previous) != 0 {
+		t.Errorf("previous CPU samples %v, want the ones of the scrapes only", c.cpuSamples.previous)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricDesc defines a metric of the docker collector, the name is prefixed
+// with --metrics.namespace. Per-container metrics carry the container labels
+// followed by their own labels.
+type metricDesc struct {
+	name      string
+	help      string
+	labels    []string
+	container bool
+
+	// labels depending on the configuration, added after labels
+	configLabels func(cfg *config) []string
+}
+
+// all metrics of the docker collector
+var dockerMetrics []*metricDesc
+
+// newContainerDesc defines a per-container metric of the docker collector.
+func newContainerDesc(name, help string, labels ...string) *metricDesc {
+	d := &metricDesc{name: name, help: help, labels: labels, container: true}
+	dockerMetrics = append(dockerMetrics, d)
+	return d
+}
+
+// newDesc defines a metric of the docker collector without container labels.
+func newDesc(name, help string, labels ...string) *metricDesc {
+	d := &metricDesc{name: name, help: help, labels: labels}
+	dockerMetrics = append(dockerMetrics, d)
+	return d
+}
+
+// descSet holds the descriptors of all metrics of the docker collector for a
+// set of container label names. They are created once and reused by all
+// scrapes until the label names change, i.e. the label map is reloaded.
+type descSet struct {
+	containerLabels []string
+	descs           map[*metricDesc]*prometheus.Desc
+
+	// reverse of descs
+	metrics map[*prometheus.Desc]*metricDesc
+}
+
+func newDescSet(cfg *config, containerLabels []string) *descSet {
+	s := &descSet{
+		containerLabels: containerLabels,
+		descs:           make(map[*metricDesc]*prometheus.Desc, len(dockerMetrics)),
+		metrics:         make(map[*prometheus.Desc]*metricDesc, len(dockerMetrics)),
+	}
+
+	for _, d := range dockerMetrics {
+		var labels []string
+		if d.container {
+			labels = append(labels, containerLabels...)
+		}
+		labels = append(labels, d.labels...)
+		if d.configLabels != nil {
+			labels = append(labels, d.configLabels(cfg)...)
+		}
+
+		desc := prometheus.NewDesc(prometheus.BuildFQName(cfg.namespace, "", d.name), d.help, labels, nil)
+		s.descs[d] = desc
+		s.metrics[desc] = d
+	}
+
+	return s
+}
+
+func (s *descSet) get(d *metricDesc) *prometheus.Desc {
+	return s.descs[d]
+}
+
+// metric returns the definition of a descriptor of the set.
+func (s *descSet) metric(desc *prometheus.Desc) (*metricDesc, bool) {
+	d, ok := s.metrics[desc]
+	return d, ok
+}
+
+func (s *descSet) describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range s.descs {
+		ch <- desc
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	containerDeviceInfoDesc = newContainerDesc(
+		"container_device_info",
+		"Host devices mapped into the container",
+		"host_path",
+		"container_path",
+		"permissions",
+	)
+)
+
+const (
+	partitionsFile = "/proc/partitions"
+	sysBlockDir    = "/sys/block"
+
+	// minimum time between two re-reads of the partition table triggered by unknown devices
+	deviceRefreshInterval = time.Minute
+)
+
+// blockDevices resolves block device numbers (major:minor) to device names.
+// The mapping is read from /proc/partitions and cached; it is re-read when an
+// unknown device shows up. If the partition table is not readable, the numeric
+// form is returned.
+type blockDevices struct {
+	mu          sync.Mutex
+	names       map[string]string
+	lastRefresh time.Time
+}
+
+func newBlockDevices() *blockDevices {
+	d := &blockDevices{}
+	d.mu.Lock()
+	d.refresh()
+	d.mu.Unlock()
+	return d
+}
+
+func (d *blockDevices) name(major, minor uint64) string {
+	key := fmt.Sprintf("%d:%d", major, minor)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if name, ok := d.names[key]; ok {
+		return name
+	}
+
+	// new device (hotplug, new dm device), try again with a fresh table
+	if time.Since(d.lastRefresh) >= deviceRefreshInterval {
+		d.refresh()
+		if name, ok := d.names[key]; ok {
+			return name
+		}
+	}
+
+	return key
+}
+
+// refresh re-reads the partition table, the caller must hold the lock.
+func (d *blockDevices) refresh() {
+	d.lastRefresh = time.Now()
+
+	f, err := os.Open(partitionsFile)
+	if err != nil {
+		if d.names == nil {
+			log.Debug("can't read partition table, using numeric device names: ", err)
+		}
+		d.names = map[string]string{}
+		return
+	}
+	defer f.Close()
+
+	names := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// major minor #blocks name
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || fields[0] == "major" {
+			continue
+		}
+		names[fields[0]+":"+fields[1]] = friendlyDeviceName(fields[3])
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error("can't read partition table: ", err)
+	}
+
+	d.names = names
+}
+
+// friendlyDeviceName resolves device mapper devices (dm-N) to their mapped name.
+func friendlyDeviceName(dev string) string {
+	if !strings.HasPrefix(dev, "dm-") {
+		return dev
+	}
+
+	name, err := os.ReadFile(filepath.Join(sysBlockDir, dev, "dm", "name"))
+	if err != nil {
+		return dev
+	}
+
+	if n := strings.TrimSpace(string(name)); n != "" {
+		return n
+	}
+	return dev
+}
+
+// nameForPath resolves a device path (/dev/sda, /dev/mapper/vg-data) to the
+// name used for the device's blkio metrics. If the path is not accessible, the
+// file name is used.
+func (d *blockDevices) nameForPath(path string) string {
+	major, minor, err := deviceNumber(path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return d.name(major, minor)
+}
+
+func (c *DockerCollector) deviceMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	if info.HostConfig == nil {
+		return
+	}
+
+	for _, device := range info.HostConfig.Devices {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerDeviceInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(device.PathOnHost, device.PathInContainer, device.CgroupPermissions)...)
+	}
+}
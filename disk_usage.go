@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DiskUsageCollector reports the disk usage of volumes, images and the build
+// cache like docker system df. The daemon walks all volumes for it, which
+// takes minutes on big hosts, so it's fetched in the background on a slow
+// interval and the last result is served on scrape.
+type DiskUsageCollector struct {
+	cli      *dockerClient
+	interval time.Duration
+
+	volumeSizeDesc            *prometheus.Desc
+	volumesReclaimableDesc    *prometheus.Desc
+	imagesSizeDesc            *prometheus.Desc
+	imagesReclaimableDesc     *prometheus.Desc
+	buildCacheSizeDesc        *prometheus.Desc
+	buildCacheReclaimableDesc *prometheus.Desc
+
+	mu    sync.Mutex
+	usage *diskUsage
+}
+
+// diskUsage is the result of a DiskUsage call reduced to the exported values.
+type diskUsage struct {
+	volumes           map[string]int64
+	volumesReclaim    int64
+	images            int64
+	imagesReclaim     int64
+	buildCache        int64
+	buildCacheReclaim int64
+}
+
+func newDiskUsageCollector(cli *dockerClient, namespace string, interval time.Duration) *DiskUsageCollector {
+	c := &DiskUsageCollector{
+		cli:      cli,
+		interval: interval,
+
+		volumeSizeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "volume_size_bytes"),
+			"Disk usage of a local volume in bytes",
+			[]string{"volume"},
+			nil,
+		),
+		volumesReclaimableDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "volumes_reclaimable_bytes"),
+			"Disk usage of the volumes not used by any container in bytes",
+			nil,
+			nil,
+		),
+		imagesSizeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images_size_bytes"),
+			"Disk usage of all image layers in bytes",
+			nil,
+			nil,
+		),
+		imagesReclaimableDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images_reclaimable_bytes"),
+			"Disk usage of the image layers not used by any container in bytes",
+			nil,
+			nil,
+		),
+		buildCacheSizeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "build_cache_size_bytes"),
+			"Disk usage of the build cache in bytes",
+			nil,
+			nil,
+		),
+		buildCacheReclaimableDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "build_cache_reclaimable_bytes"),
+			"Disk usage of the build cache records neither in use nor shared in bytes",
+			nil,
+			nil,
+		),
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *DiskUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.volumeSizeDesc
+	ch <- c.volumesReclaimableDesc
+	ch <- c.imagesSizeDesc
+	ch <- c.imagesReclaimableDesc
+	ch <- c.buildCacheSizeDesc
+	ch <- c.buildCacheReclaimableDesc
+}
+
+func (c *DiskUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	usage := c.usage
+	c.mu.Unlock()
+
+	// nothing until the first refresh finished
+	if usage == nil {
+		return
+	}
+
+	for volume, size := range usage.volumes {
+		ch <- prometheus.MustNewConstMetric(c.volumeSizeDesc, prometheus.GaugeValue, float64(size), volume)
+	}
+	ch <- prometheus.MustNewConstMetric(c.volumesReclaimableDesc, prometheus.GaugeValue, float64(usage.volumesReclaim))
+	ch <- prometheus.MustNewConstMetric(c.imagesSizeDesc, prometheus.GaugeValue, float64(usage.images))
+	ch <- prometheus.MustNewConstMetric(c.imagesReclaimableDesc, prometheus.GaugeValue, float64(usage.imagesReclaim))
+	ch <- prometheus.MustNewConstMetric(c.buildCacheSizeDesc, prometheus.GaugeValue, float64(usage.buildCache))
+	ch <- prometheus.MustNewConstMetric(c.buildCacheReclaimableDesc, prometheus.GaugeValue, float64(usage.buildCacheReclaim))
+}
+
+func (c *DiskUsageCollector) run() {
+	for {
+		start := time.Now()
+		c.refresh()
+		log.Debugf("disk usage refreshed in %v", time.Since(start))
+
+		time.Sleep(c.interval)
+	}
+}
+
+func (c *DiskUsageCollector) refresh() {
+	du, err := c.cli.DiskUsage(context.Background(), types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.ImageObject, types.VolumeObject, types.BuildCacheObject},
+	})
+	if err != nil {
+		log.Error("can't get disk usage: ", err)
+		return
+	}
+
+	usage := &diskUsage{
+		volumes: map[string]int64{},
+		images:  du.LayersSize,
+	}
+
+	// sizes are -1 if unknown, e.g. for volumes of non-local drivers
+	for _, volume := range du.Volumes {
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		usage.volumes[volume.Name] = volume.UsageData.Size
+		if volume.UsageData.RefCount == 0 {
+			usage.volumesReclaim += volume.UsageData.Size
+		}
+	}
+
+	// like docker system df, the layers of images used by containers are
+	// not reclaimable, layers shared with other images count once
+	var used int64
+	for _, image := range du.Images {
+		if image.Containers > 0 && image.SharedSize >= 0 {
+			used += image.Size - image.SharedSize
+		}
+	}
+	usage.imagesReclaim = max(du.LayersSize-used, 0)
+
+	for _, record := range du.BuildCache {
+		if !record.Shared {
+			usage.buildCache += record.Size
+		}
+		if !record.InUse && !record.Shared {
+			usage.buildCacheReclaim += record.Size
+		}
+	}
+
+	c.mu.Lock()
+	c.usage = usage
+	c.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// dockerLabel is a docker label of the containers added to their metrics.
+type dockerLabel struct {
+	key  string
+	name string
+}
+
+// parseDockerLabels parses the comma-separated list of --labels.docker. The
+// label names are the docker label keys with invalid characters replaced by
+// underscores, com.docker.compose.service becomes com_docker_compose_service.
+func parseDockerLabels(list string) ([]dockerLabel, error) {
+	var labels []dockerLabel
+	keys := map[string]string{}
+	for _, key := range strings.Split(list, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		name := invalidLabelChars.ReplaceAllString(key, "_")
+		if name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		if strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("label %q maps to %q, names starting with __ are reserved", key, name)
+		}
+		if reservedLabels[name] {
+			return nil, fmt.Errorf("label %q maps to the reserved label name %q", key, name)
+		}
+		if other, ok := keys[name]; ok {
+			if other == key {
+				continue
+			}
+			return nil, fmt.Errorf("labels %q and %q both map to %q", other, key, name)
+		}
+		keys[name] = key
+
+		labels = append(labels, dockerLabel{key: key, name: name})
+	}
+	return labels, nil
+}
+
+func dockerLabelNames(labels []dockerLabel) []string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = label.name
+	}
+	return names
+}
+
+// dockerLabelValues returns the values of the docker labels of a container,
+// missing labels get empty values.
+func dockerLabelValues(labels []dockerLabel, containerLabels map[string]string) []string {
+	values := make([]string, len(labels))
+	for i, label := range labels {
+		values[i] = containerLabels[label.key]
+	}
+	return values
+}
+
+// labels set by docker compose on the containers it creates
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// labels added to the metrics of compose containers with --labels.compose
+var composeLabelNames = []string{"compose_project", "compose_service"}
+
+// composeLabelValues returns the compose project and service of a container,
+// empty for containers not created by compose.
+func composeLabelValues(labels map[string]string) []string {
+	return []string{labels[composeProjectLabel], labels[composeServiceLabel]}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// time to wait for the daemon when checking that it's reachable
+const pipeCheckTimeout = 10 * time.Second
+
+// bounds of the delay between connection attempts to an unreachable daemon
+const (
+	connectBackoffMin = time.Second
+	connectBackoffMax = time.Minute
+)
+
+// dockerAPI is the part of the docker API used by the docker collector, it's
+// implemented by dockerClient.
+type dockerAPI interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
+	ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error)
+	Info(ctx context.Context) (system.Info, error)
+}
+
+// dockerClient wraps the docker client and counts the API calls made through it.
+type dockerClient struct {
+	*client.Client
+
+	filter *containerFilter
+
+	// number of calls per operation
+	apiCalls *prometheus.CounterVec
+}
+
+// newDockerClientFromConfig creates the client for an endpoint with the
+// container filters of the configuration.
+func newDockerClientFromConfig(cfg *config, endpoint dockerEndpoint) (*dockerClient, error) {
+	filter, err := newContainerFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newDockerClient(endpoint, filter, cfg.namespace)
+}
+
+// newDockerClient creates a client for the endpoint, settings missing there
+// are taken from the DOCKER_* environment variables. Without both, the client
+// uses the platform's default, the named pipe npipe:////./pipe/docker_engine
+// on Windows.
+func newDockerClient(endpoint dockerEndpoint, filter *containerFilter, namespace string) (*dockerClient, error) {
+	opts := []client.Opt{client.FromEnv}
+	if endpoint.host != "" {
+		opts = append(opts, client.WithHost(endpoint.host))
+	}
+	if endpoint.tlsCA != "" || endpoint.tlsCert != "" {
+		opts = append(opts, client.WithTLSClientConfig(endpoint.tlsCA, endpoint.tlsCert, endpoint.tlsKey))
+	}
+	if endpoint.apiVersion != "" {
+		opts = append(opts, client.WithVersion(endpoint.apiVersion))
+	} else {
+		opts = append(opts, client.WithAPIVersionNegotiation())
+	}
+
+	apiClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// a named pipe that can't be opened only surfaces as an opaque error on
+	// the first request, check it right away
+	if strings.HasPrefix(apiClient.DaemonHost(), "npipe://") {
+		if runtime.GOOS != "windows" {
+			return nil, fmt.Errorf("named pipe %s is only supported on Windows", apiClient.DaemonHost())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pipeCheckTimeout)
+		defer cancel()
+		if _, err := apiClient.Ping(ctx); err != nil {
+			return nil, errors.Join(
+				fmt.Errorf("can't access the docker named pipe %s, is the daemon running and does dex run as a member of the docker-users group or as administrator?", apiClient.DaemonHost()),
+				err,
+			)
+		}
+	}
+
+	return &dockerClient{
+		Client: apiClient,
+		filter: filter,
+		apiCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exporter_docker_api_calls_total",
+			Help:      "Number of docker API calls made by the exporter",
+		}, []string{"operation"}),
+	}, nil
+}
+
+func (c *dockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	c.apiCalls.WithLabelValues("container_list").Inc()
+	containers, err := c.Client.ContainerList(ctx, c.filter.listOptions(options))
+	if err != nil {
+		return containers, err
+	}
+
+	selected := containers[:0]
+	for _, container := range containers {
+		var networks map[string]*network.EndpointSettings
+		if container.NetworkSettings != nil {
+			networks = container.NetworkSettings.Networks
+		}
+		if c.filter.selects(containerName(container), container.Labels, networks) {
+			selected = append(selected, container)
+		} else {
+			log.WithField("container", containerName(container)).Debug("container not selected by the name or network filter")
+		}
+	}
+	return selected, nil
+}
+
+func (c *dockerClient) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
+	c.apiCalls.WithLabelValues("container_stats").Inc()
+	return c.Client.ContainerStats(ctx, containerID, stream)
+}
+
+func (c *dockerClient) ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error) {
+	c.apiCalls.WithLabelValues("container_stats").Inc()
+	return c.Client.ContainerStatsOneShot(ctx, containerID)
+}
+
+func (c *dockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	c.apiCalls.WithLabelValues("container_inspect").Inc()
+	return c.Client.ContainerInspect(ctx, containerID)
+}
+
+func (c *dockerClient) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	c.apiCalls.WithLabelValues("image_inspect").Inc()
+	return c.Client.ImageInspectWithRaw(ctx, imageID)
+}
+
+func (c *dockerClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
+	c.apiCalls.WithLabelValues("service_list").Inc()
+	return c.Client.ServiceList(ctx, options)
+}
+
+func (c *dockerClient) Info(ctx context.Context) (system.Info, error) {
+	c.apiCalls.WithLabelValues("info").Inc()
+	return c.Client.Info(ctx)
+}
+
+func (c *dockerClient) Ping(ctx context.Context) (types.Ping, error) {
+	c.apiCalls.WithLabelValues("ping").Inc()
+	return c.Client.Ping(ctx)
+}
+
+func (c *dockerClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	c.apiCalls.WithLabelValues("disk_usage").Inc()
+	return c.Client.DiskUsage(ctx, options)
+}
+
+func (c *dockerClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	c.apiCalls.WithLabelValues("version").Inc()
+	return c.Client.ServerVersion(ctx)
+}
+
+func (c *dockerClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	c.apiCalls.WithLabelValues("events").Inc()
+	return c.Client.Events(ctx, options)
+}
+
+// waitForDaemon pings the daemon until it answers, doubling the delay between
+// attempts up to connectBackoffMax, and logs its version. Only the first
+// failure is logged above debug level, scrapes log the failures meanwhile.
+func (c *dockerClient) waitForDaemon(ctx context.Context) error {
+	backoff := connectBackoffMin
+	for attempt := 1; ; attempt++ {
+		err := c.connect(ctx)
+		if err == nil {
+			return nil
+		}
+		level := log.DebugLevel
+		if attempt == 1 {
+			level = log.WarnLevel
+		}
+		log.StandardLogger().Logf(level, "can't reach the docker daemon at %s, retrying in %v: %v", c.DaemonHost(), backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, connectBackoffMax)
+	}
+}
+
+func (c *dockerClient) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pipeCheckTimeout)
+	defer cancel()
+
+	if _, err := c.Ping(ctx); err != nil {
+		return err
+	}
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("connected to docker %s (API %s, %s/%s) at %s", version.Version, c.ClientVersion(), version.Os, version.Arch, c.DaemonHost())
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// label distinguishing the metrics of several docker daemons
+const hostLabel = "host"
+
+// dockerEndpoint is a docker daemon and the options to connect to it.
+type dockerEndpoint struct {
+	host string
+
+	// value of the host label, the metrics have no host label if empty
+	alias string
+
+	// client certificate and CA to verify the daemon, all optional
+	tlsCA   string
+	tlsCert string
+	tlsKey  string
+
+	// negotiated with the daemon if empty
+	apiVersion string
+}
+
+// parseEndpoints creates the endpoints of the --docker.host flags, given as
+// URL or alias=URL. Without flags there's a single endpoint taken from the
+// DOCKER_* environment variables. A single endpoint only gets a host label if
+// it has an alias, several endpoints always do.
+func parseEndpoints(cfg *config) ([]dockerEndpoint, error) {
+	hosts := cfg.dockerHosts
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+
+	var endpoints []dockerEndpoint
+	aliases := map[string]string{}
+	for _, host := range hosts {
+		endpoint := dockerEndpoint{
+			host:       host,
+			apiVersion: cfg.dockerAPIVersion,
+		}
+
+		// URLs contain = only after the scheme
+		if alias, rest, found := strings.Cut(host, "="); found && !strings.Contains(alias, "://") {
+			if alias == "" {
+				return nil, fmt.Errorf("invalid --docker.host %q, empty alias", host)
+			}
+			endpoint.alias = alias
+			endpoint.host = rest
+		} else if len(hosts) > 1 {
+			endpoint.alias = defaultAlias(host)
+		}
+
+		if other, ok := aliases[endpoint.alias]; ok && endpoint.alias != "" {
+			return nil, fmt.Errorf("--docker.host %q and %q both have the alias %q, set one with alias=URL", other, host, endpoint.alias)
+		}
+		aliases[endpoint.alias] = host
+
+		// the TLS options are meant for daemons listening on TCP, a client
+		// using TLS can't talk to unix sockets and named pipes
+		if endpoint.host == "" || strings.HasPrefix(endpoint.host, "tcp://") {
+			endpoint.tlsCA = cfg.dockerTLSCA
+			endpoint.tlsCert = cfg.dockerTLSCert
+			endpoint.tlsKey = cfg.dockerTLSKey
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, nil
+}
+
+// defaultAlias is the address of TCP daemons and local for all others.
+func defaultAlias(host string) string {
+	if u, err := url.Parse(host); err == nil && u.Scheme == "tcp" && u.Host != "" {
+		return u.Host
+	}
+	return "local"
+}
+
+// hostLabeled reports whether the metrics carry the host label.
+func hostLabeled(endpoints []dockerEndpoint) bool {
+	for _, endpoint := range endpoints {
+		if endpoint.alias != "" {
+			return true
+		}
+	}
+	return false
+}
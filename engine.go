@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+const engineMetricsTimeout = 5 * time.Second
+
+// EngineCollector re-exposes selected metrics of the docker daemon's own
+// metrics endpoint (metrics-addr in daemon.json) with the dex_engine_ prefix.
+type EngineCollector struct {
+	url    string
+	allow  *regexp.Regexp
+	prefix string
+	client *http.Client
+
+	mu      sync.Mutex
+	failing bool
+}
+
+func newEngineCollector(url string, allow *regexp.Regexp, namespace string) *EngineCollector {
+	return &EngineCollector{
+		url:    url,
+		allow:  allow,
+		prefix: namespace + "_engine_",
+		client: &http.Client{Timeout: engineMetricsTimeout},
+	}
+}
+
+func (c *EngineCollector) Describe(_ chan<- *prometheus.Desc) {
+
+}
+
+func (c *EngineCollector) Collect(ch chan<- prometheus.Metric) {
+	families, err := c.scrape()
+
+	// warn once per outage, the endpoint is experimental and often disabled
+	c.mu.Lock()
+	if err != nil && !c.failing {
+		log.Warn("can't read docker engine metrics, skipping them until the endpoint recovers: ", err)
+	} else if err == nil && c.failing {
+		log.Info("docker engine metrics available again")
+	}
+	c.failing = err != nil
+	c.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	for name, family := range families {
+		if !c.allow.MatchString(name) {
+			continue
+		}
+		engineMetrics(ch, c.prefix, family)
+	}
+}
+
+func (c *EngineCollector) scrape() (map[string]*dto.MetricFamily, error) {
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// engineMetrics converts a scraped metric family to const metrics with the
+// engine prefix, engine_daemon_foo becomes dex_engine_daemon_foo.
+func engineMetrics(ch chan<- prometheus.Metric, prefix string, family *dto.MetricFamily) {
+	name := prefix + strings.TrimPrefix(family.GetName(), "engine_")
+
+	for _, m := range family.GetMetric() {
+		names := make([]string, 0, len(m.GetLabel()))
+		values := make([]string, 0, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			names = append(names, label.GetName())
+			values = append(values, label.GetValue())
+		}
+		desc := prometheus.NewDesc(name, family.GetHelp(), names, nil)
+
+		var metric prometheus.Metric
+		var err error
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+		case dto.MetricType_GAUGE:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+		case dto.MetricType_UNTYPED:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+		case dto.MetricType_HISTOGRAM:
+			buckets := map[float64]uint64{}
+			for _, b := range m.GetHistogram().GetBucket() {
+				// +Inf is implied by the sample count
+				if math.IsInf(b.GetUpperBound(), 1) {
+					continue
+				}
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			metric, err = prometheus.NewConstHistogram(desc, m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(), buckets, values...)
+		case dto.MetricType_SUMMARY:
+			quantiles := map[float64]float64{}
+			for _, q := range m.GetSummary().GetQuantile() {
+				quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			metric, err = prometheus.NewConstSummary(desc, m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum(), quantiles, values...)
+		default:
+			continue
+		}
+
+		if err != nil {
+			log.Debugf("can't convert engine metric %s: %v", name, err)
+			continue
+		}
+		ch <- metric
+	}
+}
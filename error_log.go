@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// time repeated errors of a container are logged at debug level before a
+// summary is logged
+const errorLogInterval = time.Hour
+
+type errorLogEntry struct {
+	since   time.Time
+	repeats int
+}
+
+// containerErrorLog deduplicates the errors of containers that fail on every
+// scrape, e.g. because of malformed stats. The first error of a kind is logged
+// at error level, repeats at debug level, and once errorLogInterval passed the
+// next error is logged at error level again with the number of repeats.
+type containerErrorLog struct {
+	mu      sync.Mutex
+	entries map[string]map[string]*errorLogEntry
+	names   map[string]string
+}
+
+func newContainerErrorLog() *containerErrorLog {
+	return &containerErrorLog{
+		entries: map[string]map[string]*errorLogEntry{},
+		names:   map[string]string{},
+	}
+}
+
+// log logs err of the container with ID id, kind is the message like
+// "can't read api stats".
+func (l *containerErrorLog) log(id, cName, kind string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.names[id] = cName
+	kinds, ok := l.entries[id]
+	if !ok {
+		kinds = map[string]*errorLogEntry{}
+		l.entries[id] = kinds
+	}
+
+	logger := log.WithField("container", cName)
+	entry, ok := kinds[kind]
+	switch {
+	case !ok:
+		kinds[kind] = &errorLogEntry{since: time.Now()}
+		logger.Errorf("%s: %v", kind, err)
+	case time.Since(entry.since) < errorLogInterval:
+		entry.repeats++
+		logger.Debugf("%s: %v", kind, err)
+	default:
+		logger.Errorf("%s, repeated %d times in the last %v: %v", kind, entry.repeats, time.Since(entry.since).Round(time.Minute), err)
+		entry.since = time.Now()
+		entry.repeats = 0
+	}
+}
+
+// prune drops the entries of all containers not in ids and returns their
+// names.
+func (l *containerErrorLog) prune(ids map[string]bool) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var removed []string
+	for id := range l.names {
+		if !ids[id] {
+			removed = append(removed, l.names[id])
+			delete(l.names, id)
+			delete(l.entries, id)
+		}
+	}
+	return removed
+}
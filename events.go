@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	log "github.com/sirupsen/logrus"
+)
+
+// eventsListener is a collector derived from the docker events stream.
+type eventsListener interface {
+	// sync is called with the container list after every subscription, the
+	// events received afterwards may be older than the list.
+	sync(containers []types.Container, at time.Time)
+	// handle is called for every container event of the stream.
+	handle(msg events.Message)
+	// replay is called for the events between the last checkpoint of the
+	// state file and the start of dex, the containers may be gone already.
+	replay(msg events.Message)
+}
+
+// eventsWatcher follows the container events of a daemon for all listeners
+// with a single subscription. The stream is resubscribed with increasing
+// delays while the daemon is not reachable.
+type eventsWatcher struct {
+	cli       *dockerClient
+	listeners []eventsListener
+
+	// nil without --state.file
+	state *stateFile
+}
+
+func newEventsWatcher(cli *dockerClient) *eventsWatcher {
+	return &eventsWatcher{cli: cli}
+}
+
+func (w *eventsWatcher) add(l eventsListener) {
+	w.listeners = append(w.listeners, l)
+}
+
+func (w *eventsWatcher) run() {
+	if w.state != nil {
+		w.state.restore(w.cli, w.listeners)
+		go w.state.checkpoints()
+	}
+
+	backoff := connectBackoffMin
+	for {
+		start := time.Now()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// subscribe before listing, so no transition between both gets lost
+		messages, errs := w.cli.Events(ctx, types.EventsOptions{
+			Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+		})
+
+		if err := w.sync(); err != nil {
+			log.Error("can't list containers: ", err)
+		} else {
+			w.watch(messages, errs)
+		}
+
+		cancel()
+
+		// a stream that was up for a while broke, e.g. by a daemon restart
+		if time.Since(start) > connectBackoffMax {
+			backoff = connectBackoffMin
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, connectBackoffMax)
+	}
+}
+
+// sync passes the container list to the listeners, events may have been
+// missed while the stream was interrupted.
+func (w *eventsWatcher) sync() error {
+	containers, err := w.cli.ContainerList(context.Background(), container.ListOptions{All: true})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, l := range w.listeners {
+		l.sync(containers, now)
+	}
+	return nil
+}
+
+// watch passes the events of the stream to the listeners until it breaks.
+func (w *eventsWatcher) watch(messages <-chan events.Message, errs <-chan error) {
+	for {
+		select {
+		case msg := <-messages:
+			for _, l := range w.listeners {
+				l.handle(msg)
+			}
+		case err := <-errs:
+			log.Error("events stream interrupted: ", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// metrics depending on the container stats call
+	statsMetrics = []string{
+		"block_io_read_bytes_total",
+		"block_io_write_bytes_total",
+		"block_io_device_read_bytes_total",
+		"block_io_device_write_bytes_total",
+		"block_io_reads_total",
+		"block_io_writes_total",
+		"memory_usage_bytes",
+		"memory_total_bytes",
+		"memory_utilization_percent",
+		"memory_working_set_bytes",
+		"memory_cache_bytes",
+		"memory_cgroup_version",
+		"memory_commit_bytes",
+		"memory_commit_peak_bytes",
+		"memory_limit_set",
+		"memory_failcnt_total",
+		"memory_oom_events_total",
+		"network_rx_bytes_total",
+		"network_tx_bytes_total",
+		"network_rx_packets_total",
+		"network_tx_packets_total",
+		"network_rx_errors_total",
+		"network_tx_errors_total",
+		"network_rx_dropped_total",
+		"network_tx_dropped_total",
+		"network_interface_rx_bytes_total",
+		"network_interface_tx_bytes_total",
+		"cpu_utilization_percent",
+		"cpu_utilization_of_limit_percent",
+		"cpu_utilization_seconds_total",
+		"cpu_throttling_periods_total",
+		"cpu_throttled_periods_total",
+		"cpu_throttled_seconds_total",
+		"cpu_usage_seconds_total",
+		"pids_current",
+		"pids_limit",
+		"container_stats_backoff",
+		"container_scrape_duration_seconds",
+		"container_scrape_success",
+		"host_cpu_utilization_seconds_total",
+		"host_cpu_utilization_percent",
+		"host_memory_usage_bytes",
+		"host_network_rx_bytes_total",
+		"host_network_tx_bytes_total",
+		"host_block_io_read_bytes_total",
+		"host_block_io_write_bytes_total",
+		// names of --metrics.legacy-names
+		"block_io_read_bytes",
+		"block_io_write_bytes",
+		"block_io_device_read_bytes",
+		"block_io_device_write_bytes",
+		"network_rx_bytes",
+		"network_tx_bytes",
+		"network_interface_rx_bytes",
+		"network_interface_tx_bytes",
+		"host_network_rx_bytes",
+		"host_network_tx_bytes",
+		"host_block_io_read_bytes",
+		"host_block_io_write_bytes",
+		"memory_usage_bytes_counter",
+		"memory_total_bytes_counter",
+		"pids_current_counter",
+	}
+
+	// metrics depending on the container inspect call
+	inspectMetrics = []string{
+		"container_privileged",
+		"container_readonly_rootfs",
+		"container_capability_info",
+		"container_security_info",
+		"container_user_info",
+		"container_runs_as_root",
+		"container_exec_sessions",
+		"container_restarts_total",
+		"container_config_info",
+		"container_exit_code",
+		"container_oom_killed",
+		"container_start_time_seconds",
+		"container_tmpfs_limit_bytes",
+		"container_tmpfs_usage_bytes",
+		"container_image_outdated",
+		"container_blkio_limit",
+		"cpu_limit_cpus",
+		"memory_limit_bytes",
+		"memory_reservation_bytes",
+		"cpu_utilization_of_limit_percent",
+		"container_device_info",
+		"container_healthcheck_defined",
+		"container_healthcheck_interval_seconds",
+		"container_healthcheck_timeout_seconds",
+		"container_healthcheck_retries",
+		"container_healthcheck_start_period_seconds",
+		"container_healthy",
+		"container_health_status",
+		"container_gpu_memory_used_bytes",
+		"container_gpu_utilization_percent",
+		"container_tcp_connections",
+		"container_udp_sockets",
+		"pressure_cpu_waiting_seconds_total",
+		"pressure_memory_waiting_seconds_total",
+		"pressure_io_waiting_seconds_total",
+	}
+)
+
+// compileMetricsOnly builds the allow-list regexp from a comma-separated list of
+// metric names or regular expressions.
+func compileMetricsOnly(list string) (*regexp.Regexp, error) {
+	var exprs []string
+	for _, expr := range strings.Split(list, ",") {
+		if expr = strings.TrimSpace(expr); expr != "" {
+			exprs = append(exprs, expr)
+		}
+	}
+
+	return regexp.Compile("^(?:" + strings.Join(exprs, "|") + ")$")
+}
+
+// anyAllowed reports whether at least one of the metrics passes --metrics.only,
+// names are given without the namespace.
+func (cfg *config) anyAllowed(names ...string) bool {
+	if cfg.metricsOnlyRegex == nil {
+		return true
+	}
+
+	for _, name := range names {
+		if cfg.metricsOnlyRegex.MatchString(prometheus.BuildFQName(cfg.namespace, "", name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// filteredCollector drops all metrics of the wrapped collector not matching
+// the allow-list.
+type filteredCollector struct {
+	prometheus.Collector
+	allow *regexp.Regexp
+}
+
+func filterCollector(c prometheus.Collector, allow *regexp.Regexp) prometheus.Collector {
+	if allow == nil {
+		return c
+	}
+	return &filteredCollector{Collector: c, allow: allow}
+}
+
+func (f *filteredCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	go func() {
+		for m := range metrics {
+			if f.allow.MatchString(metricName(m)) {
+				ch <- m
+			}
+		}
+		close(done)
+	}()
+
+	f.Collector.Collect(metrics)
+	close(metrics)
+	<-done
+}
+
+// metricName extracts the fully-qualified name from a metric's descriptor.
+func metricName(m prometheus.Metric) string {
+	// Desc{fqName: "dex_container_running", help: ...
+	_, name, _ := strings.Cut(m.Desc().String(), `fqName: "`)
+	name, _, _ = strings.Cut(name, `"`)
+	return name
+}
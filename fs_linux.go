@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// filesystem magic numbers from statfs(2)
+var networkFSTypes = map[uint32]bool{
+	0x6969:     true, // NFS
+	0xff534d42: true, // CIFS
+	0xfe534d42: true, // SMB2
+	0x517b:     true, // SMB
+	0x00c36400: true, // Ceph
+	0x65735546: true, // FUSE (sshfs, glusterfs, s3fs, ...)
+	0x564c:     true, // NCP
+	0x01021997: true, // 9p
+	0x013111a8: true, // IBRIX
+	0x47504653: true, // GPFS
+	0x0bd00bd0: true, // Lustre
+}
+
+// isNetworkFS reports whether path is located on a network filesystem.
+func isNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	return networkFSTypes[uint32(st.Type)]
+}
+
+// fsUsedBytes returns the used bytes of the filesystem path is located on.
+func fsUsedBytes(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return (st.Blocks - st.Bfree) * uint64(st.Bsize), nil
+}
+
+// deviceNumber returns major and minor number of the device file at path.
+func deviceNumber(path string) (major, minor uint64, err error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, 0, err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, fmt.Errorf("%s is not a block device", path)
+	}
+	rdev := uint64(st.Rdev)
+	return uint64(unix.Major(rdev)), uint64(unix.Minor(rdev)), nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// isNetworkFS reports whether path is located on a network filesystem. Only
+// implemented on linux.
+func isNetworkFS(_ string) bool {
+	return false
+}
+
+// fsUsedBytes returns the used bytes of the filesystem path is located on.
+// Only implemented on linux.
+func fsUsedBytes(_ string) (uint64, error) {
+	return 0, errors.New("not supported on this platform")
+}
+
+// deviceNumber returns major and minor number of the device file at path.
+// Only implemented on linux.
+func deviceNumber(_ string) (major, minor uint64, err error) {
+	return 0, 0, errors.New("not supported on this platform")
+}
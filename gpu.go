@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	containerGPUMemoryUsedBytesDesc = newContainerDesc(
+		"container_gpu_memory_used_bytes",
+		"Used memory of a GPU reserved by the container in bytes",
+		"gpu",
+	)
+	containerGPUUtilizationPercentDesc = newContainerDesc(
+		"container_gpu_utilization_percent",
+		"Utilization of a GPU reserved by the container in percent",
+		"gpu",
+	)
+)
+
+type gpuStat struct {
+	index       int
+	uuid        string
+	memoryUsed  uint64
+	utilization uint32
+}
+
+// gpuReader reads the current state of all GPUs of the host.
+type gpuReader interface {
+	gpus() ([]gpuStat, error)
+}
+
+func (c *DockerCollector) gpuMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	requested := requestedGPUs(info)
+	if len(requested) == 0 {
+		return
+	}
+
+	gpus, err := c.gpus.gpus()
+	if err != nil {
+		log.Error("can't read GPU stats: ", err)
+		return
+	}
+
+	for _, gpu := range gpus {
+		if !requested["all"] && !requested[strconv.Itoa(gpu.index)] && !requested[gpu.uuid] {
+			continue
+		}
+
+		index := strconv.Itoa(gpu.index)
+		ch <- prometheus.MustNewConstMetric(l.desc(containerGPUMemoryUsedBytesDesc), prometheus.GaugeValue, float64(gpu.memoryUsed), l.valuesWith(index)...)
+		ch <- prometheus.MustNewConstMetric(l.desc(containerGPUUtilizationPercentDesc), prometheus.GaugeValue, float64(gpu.utilization), l.valuesWith(index)...)
+	}
+}
+
+// requestedGPUs returns the GPUs (index, UUID or "all") reserved by a container,
+// either with device requests (--gpus) or the NVIDIA_VISIBLE_DEVICES variable
+// of the nvidia runtime.
+func requestedGPUs(info *types.ContainerJSON) map[string]bool {
+	requested := map[string]bool{}
+
+	if info.HostConfig != nil {
+		for _, request := range info.HostConfig.DeviceRequests {
+			if !isGPURequest(request.Driver, request.Capabilities) {
+				continue
+			}
+			if request.Count == -1 {
+				requested["all"] = true
+			}
+			for _, id := range request.DeviceIDs {
+				requested[id] = true
+			}
+			// --gpus 2 reserves the first GPUs
+			for i := 0; i < request.Count; i++ {
+				requested[strconv.Itoa(i)] = true
+			}
+		}
+	}
+
+	if info.Config != nil {
+		for _, env := range info.Config.Env {
+			value, ok := strings.CutPrefix(env, "NVIDIA_VISIBLE_DEVICES=")
+			if !ok || value == "none" || value == "void" {
+				continue
+			}
+			for _, id := range strings.Split(value, ",") {
+				requested[strings.TrimSpace(id)] = true
+			}
+		}
+	}
+
+	return requested
+}
+
+func isGPURequest(driver string, capabilities [][]string) bool {
+	if driver == "nvidia" {
+		return true
+	}
+	for _, caps := range capabilities {
+		for _, capability := range caps {
+			if capability == "gpu" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+//go:build nvml
+
+package main
+
+import (
+	"errors"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+type nvmlReader struct{}
+
+// newGPUReader initializes NVML. It fails on hosts without the NVIDIA driver.
+func newGPUReader() (gpuReader, error) {
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return nil, errors.New(nvml.ErrorString(ret))
+	}
+	return &nvmlReader{}, nil
+}
+
+func (r *nvmlReader) gpus() ([]gpuStat, error) {
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		return nil, errors.New(nvml.ErrorString(ret))
+	}
+
+	gpus := make([]gpuStat, 0, count)
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			return nil, errors.New(nvml.ErrorString(ret))
+		}
+
+		uuid, ret := device.GetUUID()
+		if ret != nvml.SUCCESS {
+			return nil, errors.New(nvml.ErrorString(ret))
+		}
+		memory, ret := device.GetMemoryInfo()
+		if ret != nvml.SUCCESS {
+			return nil, errors.New(nvml.ErrorString(ret))
+		}
+		utilization, ret := device.GetUtilizationRates()
+		if ret != nvml.SUCCESS {
+			return nil, errors.New(nvml.ErrorString(ret))
+		}
+
+		gpus = append(gpus, gpuStat{
+			index:       i,
+			uuid:        uuid,
+			memoryUsed:  memory.Used,
+			utilization: utilization.Gpu,
+		})
+	}
+
+	return gpus, nil
+}
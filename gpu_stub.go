@@ -0,0 +1,10 @@
+//go:build !nvml
+
+package main
+
+import "errors"
+
+// newGPUReader fails, dex was built without NVML support.
+func newGPUReader() (gpuReader, error) {
+	return nil, errors.New("built without NVML support, rebuild with -tags nvml")
+}
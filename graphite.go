@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/graphite"
+	log "github.com/sirupsen/logrus"
+)
+
+// startGraphiteBridge pushes the metrics of gatherer to a Carbon endpoint every
+// interval. Labels are flattened into the metric path
+// (prefix.metric.container_name.web) unless useTags is set.
+func startGraphiteBridge(ctx context.Context, gatherer prometheus.Gatherer, address, prefix string, interval time.Duration, useTags bool) error {
+	bridge, err := graphite.NewBridge(&graphite.Config{
+		URL:           address,
+		Gatherer:      gatherer,
+		Prefix:        prefix,
+		Interval:      interval,
+		Timeout:       interval,
+		UseTags:       useTags,
+		ErrorHandling: graphite.ContinueOnError,
+		Logger:        log.StandardLogger(),
+	})
+	if err != nil {
+		return err
+	}
+
+	go bridge.Run(ctx)
+
+	return nil
+}
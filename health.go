@@ -0,0 +1,92 @@
+package main
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	containerHealthcheckDefinedDesc = newContainerDesc(
+		"container_healthcheck_defined",
+		"1 if the container has a healthcheck, 0 otherwise",
+	)
+	containerHealthcheckIntervalSecondsDesc = newContainerDesc(
+		"container_healthcheck_interval_seconds",
+		"Configured healthcheck interval in seconds",
+	)
+	containerHealthcheckTimeoutSecondsDesc = newContainerDesc(
+		"container_healthcheck_timeout_seconds",
+		"Configured healthcheck timeout in seconds",
+	)
+	containerHealthcheckRetriesDesc = newContainerDesc(
+		"container_healthcheck_retries",
+		"Configured number of healthcheck retries",
+	)
+	containerHealthcheckStartPeriodSecondsDesc = newContainerDesc(
+		"container_healthcheck_start_period_seconds",
+		"Configured healthcheck start period in seconds",
+	)
+	containerHealthyDesc = newContainerDesc(
+		"container_healthy",
+		"1 if the container's healthcheck passes, 0 if the container is unhealthy",
+	)
+	containerHealthStatusDesc = newContainerDesc(
+		"container_health_status",
+		"Current health status of the container (starting, healthy, unhealthy), value is 1 for the current status",
+		"status",
+	)
+)
+
+// docker defaults for healthcheck options that are not set
+const (
+	defaultHealthcheckInterval = 30 * time.Second
+	defaultHealthcheckTimeout  = 30 * time.Second
+	defaultHealthcheckRetries  = 3
+)
+
+func (c *DockerCollector) healthcheckMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	if info.Config == nil {
+		return
+	}
+
+	hc := info.Config.Healthcheck
+	defined := hc != nil && len(hc.Test) > 0 && hc.Test[0] != "NONE"
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerHealthcheckDefinedDesc), prometheus.GaugeValue, boolToFloat(defined), l.values...)
+
+	if !defined {
+		return
+	}
+
+	interval, timeout, retries := hc.Interval, hc.Timeout, hc.Retries
+	if interval == 0 {
+		interval = defaultHealthcheckInterval
+	}
+	if timeout == 0 {
+		timeout = defaultHealthcheckTimeout
+	}
+	if retries == 0 {
+		retries = defaultHealthcheckRetries
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerHealthcheckIntervalSecondsDesc), prometheus.GaugeValue, interval.Seconds(), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerHealthcheckTimeoutSecondsDesc), prometheus.GaugeValue, timeout.Seconds(), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerHealthcheckRetriesDesc), prometheus.GaugeValue, float64(retries), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerHealthcheckStartPeriodSecondsDesc), prometheus.GaugeValue, hc.StartPeriod.Seconds(), l.values...)
+
+	// only running containers have a current health status
+	if info.State == nil || info.State.Health == nil || !info.State.Running {
+		return
+	}
+	status := info.State.Health.Status
+
+	if status == types.Healthy || status == types.Unhealthy {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerHealthyDesc), prometheus.GaugeValue, boolToFloat(status == types.Healthy), l.values...)
+	}
+
+	for _, s := range []string{types.Starting, types.Healthy, types.Unhealthy} {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerHealthStatusDesc), prometheus.GaugeValue, boolToFloat(status == s), l.valuesWith(s)...)
+	}
+}
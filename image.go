@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	containerImageOutdatedDesc = newContainerDesc(
+		"container_image_outdated",
+		"1 if the container's image reference resolves to a different local image than the one running, 0 otherwise",
+	)
+	containerImageSizeBytesDesc = newContainerDesc(
+		"container_image_size_bytes",
+		"Size of the container's image including all layers in bytes",
+	)
+	containerInfoDesc = newContainerDesc(
+		"container_info",
+		"Information about the container, value is always 1",
+		"image",
+		"image_id",
+	)
+)
+
+func init() {
+	containerInfoDesc.configLabels = func(cfg *config) []string {
+		var labels []string
+		// otherwise it's one of the container labels
+		if cfg.labelsContainerID == "none" {
+			labels = append(labels, "container_id")
+		}
+		if cfg.normalizeSwarmNames {
+			labels = append(labels, "raw_name")
+		}
+		if cfg.imageLabels {
+			labels = append(labels, "image_version", "image_revision", "image_source")
+		}
+		return labels
+	}
+}
+
+func (c *DockerCollector) imageMetrics(ctx context.Context, ch chan<- prometheus.Metric, container types.Container, info *types.ContainerJSON, l *containerLabels) {
+	// reference the container was created from, the container list shows the
+	// image ID instead once the tag was moved to another image
+	ref := container.Image
+	if info.Config != nil && info.Config.Image != "" {
+		ref = info.Config.Image
+	}
+
+	var outdated bool
+	switch {
+	case strings.Contains(ref, "@sha256:"), strings.HasPrefix(ref, "sha256:"):
+		// pinned by digest or ID, can't be outdated
+	default:
+		image, found, err := c.images.get(ctx, ref)
+		if err != nil {
+			c.errors.log(container.ID, l.name(), "can't inspect image", err)
+			return
+		}
+		// an untagged reference has no newer image to compare with
+		outdated = found && image.ID != container.ImageID
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerImageOutdatedDesc), prometheus.GaugeValue, boolToFloat(outdated), l.values...)
+}
+
+func (c *DockerCollector) infoMetrics(ctx context.Context, ch chan<- prometheus.Metric, container types.Container, l *containerLabels) {
+	values := l.valuesWith(container.Image, container.ImageID)
+
+	if c.cfg.labelsContainerID == "none" {
+		values = append(values, shortID(container.ID))
+	}
+
+	if c.cfg.normalizeSwarmNames {
+		values = append(values, containerName(container))
+	}
+
+	if c.cfg.imageLabels {
+		var imageLabels map[string]string
+		if image, found, err := c.images.get(ctx, container.ImageID); err != nil {
+			c.errors.log(container.ID, l.name(), "can't inspect image", err)
+		} else if found && image.Config != nil {
+			imageLabels = image.Config.Labels
+		}
+
+		values = append(values,
+			imageLabels["org.opencontainers.image.version"],
+			imageLabels["org.opencontainers.image.revision"],
+			imageLabels["org.opencontainers.image.source"],
+		)
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerInfoDesc), prometheus.GaugeValue, 1, values...)
+}
+
+func (c *DockerCollector) imageSizeMetrics(ctx context.Context, ch chan<- prometheus.Metric, container types.Container, l *containerLabels) {
+	image, found, err := c.images.get(ctx, container.ImageID)
+	if err != nil {
+		c.errors.log(container.ID, l.name(), "can't inspect image", err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerImageSizeBytesDesc), prometheus.GaugeValue, float64(image.Size), l.values...)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// maximum age of a cached image inspect result
+const imageCacheTTL = time.Minute
+
+// maximum age of a cached failed image inspect
+const imageErrorTTL = 10 * time.Second
+
+type imageEntry struct {
+	info     types.ImageInspect
+	notFound bool
+	err      error
+	fetched  time.Time
+}
+
+func (e imageEntry) found() bool {
+	return e.err == nil && !e.notFound
+}
+
+func (e imageEntry) expired() bool {
+	if e.err != nil {
+		return time.Since(e.fetched) >= imageErrorTTL
+	}
+	return time.Since(e.fetched) >= imageCacheTTL
+}
+
+// imageCache caches ImageInspect results per image reference or ID. Images
+// that don't exist (e.g. a tag that was removed) are cached as well, so they
+// are not looked up on every scrape, and failed lookups for imageErrorTTL.
+// Concurrent lookups of an image shared by many containers share one call.
+type imageCache struct {
+	cli   dockerAPI
+	calls singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]imageEntry
+	hits    uint64
+	misses  uint64
+}
+
+func newImageCache(cli dockerAPI) *imageCache {
+	return &imageCache{
+		cli:     cli,
+		entries: map[string]imageEntry{},
+	}
+}
+
+// get returns the image inspect result for ref, found is false if the image
+// doesn't exist.
+func (c *imageCache) get(ctx context.Context, ref string) (info types.ImageInspect, found bool, err error) {
+	c.mu.Lock()
+	entry, ok := c.entries[ref]
+	if ok && !entry.expired() {
+		c.hits++
+		c.mu.Unlock()
+		return entry.info, entry.found(), entry.err
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	result, err, _ := c.calls.Do(ref, func() (any, error) {
+		info, _, err := c.cli.ImageInspectWithRaw(ctx, ref)
+		entry := imageEntry{info: info, notFound: client.IsErrNotFound(err), fetched: time.Now()}
+		if err != nil && !entry.notFound {
+			// a timed out scrape says nothing about the image
+			if ctx.Err() != nil {
+				return entry, err
+			}
+			entry.err = err
+		}
+
+		c.mu.Lock()
+		c.entries[ref] = entry
+		c.mu.Unlock()
+
+		return entry, entry.err
+	})
+	entry = result.(imageEntry)
+	return entry.info, err == nil && entry.found(), err
+}
+
+// expire drops all entries older than imageCacheTTL.
+func (c *imageCache) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for ref, entry := range c.entries {
+		if entry.expired() {
+			delete(c.entries, ref)
+		}
+	}
+}
+
+func (c *imageCache) collect(ch chan<- prometheus.Metric, descs *descSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	collectCacheMetrics(ch, descs, "image", len(c.entries), c.hits, c.misses)
+}
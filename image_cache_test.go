@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestImageCacheGet(t *testing.T) {
+	tests := []struct {
+		name      string
+		images    map[string]types.ImageInspect
+		imageErr  error
+		wantFound bool
+		wantErr   bool
+	}{
+		{
+			name:      "found",
+			images:    map[string]types.ImageInspect{"nginx:1.25": {ID: "sha256:a1"}},
+			wantFound: true,
+		},
+		{
+			name: "not found",
+		},
+		{
+			name:     "daemon error",
+			imageErr: errors.New("daemon error"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := &fakeDocker{images: tt.images, imageErr: tt.imageErr}
+			c := newImageCache(cli)
+
+			// every result is cached, errors as well
+			for i := 0; i < 3; i++ {
+				_, found, err := c.get(context.Background(), "nginx:1.25")
+				if found != tt.wantFound || (err != nil) != tt.wantErr {
+					t.Fatalf("get() = %t, %v, want %t, error %t", found, err, tt.wantFound, tt.wantErr)
+				}
+			}
+			if cli.imageCalls != 1 {
+				t.Errorf("%d image inspect calls, want 1", cli.imageCalls)
+			}
+		})
+	}
+}
+
+func TestImageCacheErrorExpires(t *testing.T) {
+	cli := &fakeDocker{imageErr: errors.New("daemon error")}
+	c := newImageCache(cli)
+
+	if _, _, err := c.get(context.Background(), "nginx:1.25"); err == nil {
+		t.Fatal("get() succeeded, want the daemon error")
+	}
+
+	c.entries["nginx:1.25"] = imageEntry{err: cli.imageErr, fetched: time.Now().Add(-imageErrorTTL)}
+	cli.imageErr = nil
+	cli.images = map[string]types.ImageInspect{"nginx:1.25": {ID: "sha256:a1"}}
+	if _, found, err := c.get(context.Background(), "nginx:1.25"); err != nil || !found {
+		t.Errorf("get() after the error expired = %t, %v, want found", found, err)
+	}
+}
+
+func TestImageCacheConcurrentMisses(t *testing.T) {
+	cli := &fakeDocker{
+		images:     map[string]types.ImageInspect{"sha256:a1": {ID: "sha256:a1"}},
+		imageDelay: 50 * time.Millisecond,
+	}
+	c := newImageCache(cli)
+
+	// containers of the same image scraped at once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, found, err := c.get(context.Background(), "sha256:a1"); err != nil || !found {
+				t.Errorf("get() = %t, %v, want found", found, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if cli.imageCalls != 1 {
+		t.Errorf("%d image inspect calls, want 1", cli.imageCalls)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// errorLogs returns the number of errors logged with msg.
+func errorLogs(hook *test.Hook, msg string) int {
+	var n int
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.ErrorLevel && strings.Contains(entry.Message, msg) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestImageErrorsLoggedOnce(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	cli := &fakeDocker{
+		containers: []types.Container{
+			{ID: "a1", Names: []string{"/web-1"}, Image: "nginx:1.25", ImageID: "sha256:a1", State: "exited"},
+			{ID: "b2", Names: []string{"/web-2"}, Image: "nginx:1.25", ImageID: "sha256:a1", State: "exited"},
+		},
+		imageErr: errors.New("daemon error"),
+	}
+	cfg := testConfig()
+	cfg.imageLabels = true
+	c := newDockerCollector(cfg, cli)
+
+	for i := 0; i < 3; i++ {
+		m := collectMetrics(t, c.Collect)
+		// the info metric is reported without the image labels
+		if got := len(m["dex_container_info"]); got != 2 {
+			t.Fatalf("dex_container_info of %d containers, want 2", got)
+		}
+		if _, ok := m.get("dex_container_image_size_bytes"); ok {
+			t.Error("image size reported without image")
+		}
+	}
+
+	// once per container, not per scrape or metric
+	if got := errorLogs(hook, "can't inspect image"); got != 2 {
+		t.Errorf("%d image errors logged, want 2", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exporterCacheEntriesDesc = newDesc(
+		"exporter_cache_entries",
+		"Number of entries in an internal cache",
+		"cache",
+	)
+	exporterCacheHitsTotalDesc = newDesc(
+		"exporter_cache_hits_total",
+		"Number of lookups answered from an internal cache",
+		"cache",
+	)
+	exporterCacheMissesTotalDesc = newDesc(
+		"exporter_cache_misses_total",
+		"Number of lookups not answered from an internal cache",
+		"cache",
+	)
+)
+
+// maximum age of a cached inspect result
+const inspectCacheTTL = time.Minute
+
+type inspectEntry struct {
+	info    types.ContainerJSON
+	status  string
+	fetched time.Time
+}
+
+// inspectCache caches ContainerInspect results per container ID. An entry is
+// reused as long as the container's state and status from the container list
+// are unchanged and the entry is younger than inspectCacheTTL.
+type inspectCache struct {
+	cli dockerAPI
+
+	mu      sync.Mutex
+	entries map[string]inspectEntry
+	hits    uint64
+	misses  uint64
+}
+
+func newInspectCache(cli dockerAPI) *inspectCache {
+	return &inspectCache{
+		cli:     cli,
+		entries: map[string]inspectEntry{},
+	}
+}
+
+func (c *inspectCache) get(ctx context.Context, container types.Container) (types.ContainerJSON, error) {
+	status := container.State + "/" + container.Status
+
+	c.mu.Lock()
+	entry, ok := c.entries[container.ID]
+	if ok && entry.status == status && time.Since(entry.fetched) < inspectCacheTTL {
+		c.hits++
+		c.mu.Unlock()
+		return entry.info, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	info, err := c.cli.ContainerInspect(ctx, container.ID)
+	if err != nil {
+		return info, err
+	}
+
+	c.mu.Lock()
+	c.entries[container.ID] = inspectEntry{info: info, status: status, fetched: time.Now()}
+	c.mu.Unlock()
+
+	return info, nil
+}
+
+// prune drops the entries of all containers not in ids.
+func (c *inspectCache) prune(ids map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.entries {
+		if !ids[id] {
+			delete(c.entries, id)
+		}
+	}
+}
+
+func (c *inspectCache) collect(ch chan<- prometheus.Metric, descs *descSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	collectCacheMetrics(ch, descs, "inspect", len(c.entries), c.hits, c.misses)
+}
+
+func collectCacheMetrics(ch chan<- prometheus.Metric, descs *descSet, cache string, entries int, hits, misses uint64) {
+	ch <- prometheus.MustNewConstMetric(descs.get(exporterCacheEntriesDesc), prometheus.GaugeValue, float64(entries), cache)
+	ch <- prometheus.MustNewConstMetric(descs.get(exporterCacheHitsTotalDesc), prometheus.CounterValue, float64(hits), cache)
+	ch <- prometheus.MustNewConstMetric(descs.get(exporterCacheMissesTotalDesc), prometheus.CounterValue, float64(misses), cache)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// singleContainerCollector runs the collection path of the docker collector
+// for a single container.
+type singleContainerCollector struct {
+	collector *DockerCollector
+	container types.Container
+}
+
+func (s *singleContainerCollector) Describe(_ chan<- *prometheus.Desc) {
+
+}
+
+func (s *singleContainerCollector) Collect(ch chan<- prometheus.Metric) {
+	var probeDeadline time.Time
+	if s.collector.prober != nil {
+		probeDeadline = time.Now().Add(s.collector.prober.budget)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.collector.cfg.dockerTimeout)
+	defer cancel()
+
+	mapping := s.collector.labelMapping()
+	descs := s.collector.descriptors(mapping)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	s.collector.processContainer(ctx, s.container, ch, newHostAggregate(descs), mapping, descs, probeDeadline, &wg)
+}
+
+// findContainer looks a container up by name or ID prefix, like docker a
+// prefix matching several containers is an error.
+func findContainer(containers []types.Container, ref string) (*types.Container, error) {
+	var matches []*types.Container
+	for i, container := range containers {
+		if containerName(container) == ref || container.ID == ref {
+			return &containers[i], nil
+		}
+		if strings.HasPrefix(container.ID, ref) {
+			matches = append(matches, &containers[i])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no container %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, container := range matches {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", containerName(*container), container.ID))
+	}
+	return nil, fmt.Errorf("ID prefix %q is ambiguous, it matches %s", ref, strings.Join(candidates, ", "))
+}
+
+// runInspect prints the raw values dex reads for a single container followed
+// by all metrics it emits for it, for debugging. With several --docker.host
+// the container is looked up at the first one.
+func runInspect(cfg *config, ref string, w io.Writer) error {
+	cli, err := newDockerClientFromConfig(cfg, cfg.endpoints[0])
+	if err != nil {
+		return fmt.Errorf("can't create docker client: %w", err)
+	}
+	collector := setupCollector(cfg, cli)
+
+	containers, err := collector.cli.ContainerList(context.Background(), container.ListOptions{All: true})
+	if err != nil {
+		return fmt.Errorf("can't list containers: %w", err)
+	}
+
+	target, err := findContainer(containers, ref)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "container:      %s (%s)\n", containerName(*target), target.ID)
+	fmt.Fprintf(w, "state:          %s (%s)\n", target.State, target.Status)
+	fmt.Fprintf(w, "image:          %s (%s)\n", target.Image, target.ImageID)
+
+	if info, err := collector.daemonInfo(context.Background()); err != nil {
+		fmt.Fprintf(w, "daemon:         can't get info: %v\n", err)
+	} else {
+		fmt.Fprintf(w, "cgroup:         v%s (driver %s)\n", info.CgroupVersion, info.CgroupDriver)
+	}
+
+	if target.State == "running" {
+		stats, err := collector.containerStats(context.Background(), *target)
+		if errors.Is(err, errStatsBackoff) {
+			fmt.Fprintln(w, "stats:          skipped (backoff)")
+		} else if err != nil {
+			fmt.Fprintf(w, "stats:          can't read: %v\n", err)
+		} else {
+			printStats(w, stats)
+		}
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(filterCollector(&singleContainerCollector{collector: collector, container: *target}, cfg.metricsOnlyRegex))
+
+	families, err := reg.Gather()
+	if err != nil {
+		return fmt.Errorf("can't gather metrics: %w", err)
+	}
+
+	fmt.Fprintln(w, "\nmetrics (from a separate stats sample):")
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printStats(w io.Writer, stats *types.StatsJSON) {
+	fmt.Fprintf(w, "read:           %v (previous %v)\n", stats.Read, stats.PreRead)
+
+	fmt.Fprintf(w, "cpu total:      %d (previous %d)\n", stats.CPUStats.CPUUsage.TotalUsage, stats.PreCPUStats.CPUUsage.TotalUsage)
+	fmt.Fprintf(w, "cpu system:     %d (previous %d)\n", stats.CPUStats.SystemUsage, stats.PreCPUStats.SystemUsage)
+	fmt.Fprintf(w, "cpu online:     %d (previous %d)\n", stats.CPUStats.OnlineCPUs, stats.PreCPUStats.OnlineCPUs)
+
+	fmt.Fprintf(w, "memory usage:   %d\n", stats.MemoryStats.Usage)
+	fmt.Fprintf(w, "memory limit:   %d\n", stats.MemoryStats.Limit)
+	keys := make([]string, 0, len(stats.MemoryStats.Stats))
+	for key := range stats.MemoryStats.Stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(w, "memory stats:")
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %-24s %d\n", key, stats.MemoryStats.Stats[key])
+	}
+
+	interfaces := make([]string, 0, len(stats.Networks))
+	for name := range stats.Networks {
+		interfaces = append(interfaces, name)
+	}
+	sort.Strings(interfaces)
+	fmt.Fprintln(w, "networks:")
+	for _, name := range interfaces {
+		fmt.Fprintf(w, "  %-24s rx %d tx %d\n", name, stats.Networks[name].RxBytes, stats.Networks[name].TxBytes)
+	}
+
+	fmt.Fprintln(w, "block io:")
+	for _, b := range stats.BlkioStats.IoServiceBytesRecursive {
+		fmt.Fprintf(w, "  %d:%d %-8s %d\n", b.Major, b.Minor, b.Op, b.Value)
+	}
+
+	fmt.Fprintf(w, "pids:           %d (limit %d)\n", stats.PidsStats.Current, stats.PidsStats.Limit)
+}
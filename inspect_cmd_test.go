@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestFindContainer(t *testing.T) {
+	containers := []types.Container{
+		{ID: "abc123", Names: []string{"/web"}},
+		{ID: "abd456", Names: []string{"/db"}},
+		{ID: "fff789", Names: []string{"/abc"}},
+	}
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "web", want: "abc123"},
+		{ref: "abc123", want: "abc123"},
+		{ref: "abd", want: "abd456"},
+		// names take precedence over ID prefixes
+		{ref: "abc", want: "fff789"},
+		{ref: "ab", wantErr: "web (abc123), db (abd456)"},
+		{ref: "nope", wantErr: `no container "nope"`},
+	}
+
+	for _, tt := range tests {
+		got, err := findContainer(containers, tt.ref)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("findContainer(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("findContainer(%q) error = %v", tt.ref, err)
+			continue
+		}
+		if got.ID != tt.want {
+			t.Errorf("findContainer(%q) = %s, want %s", tt.ref, got.ID, tt.want)
+		}
+	}
+}
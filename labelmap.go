@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// label names used by dex itself, they can't be set from the label map file
+var reservedLabels = map[string]bool{
+	"container_name":      true,
+	"device":              true,
+	"destination":         true,
+	"capability":          true,
+	"cpu":                 true,
+	"action":              true,
+	"op":                  true,
+	"unit":                true,
+	"host_path":           true,
+	"host_port":           true,
+	"interface":           true,
+	"status":              true,
+	"raw_name":            true,
+	"container_path":      true,
+	"permissions":         true,
+	"gpu":                 true,
+	"image":               true,
+	"image_id":            true,
+	"container_id":        true,
+	"image_version":       true,
+	"image_revision":      true,
+	"image_source":        true,
+	"seccomp":             true,
+	"apparmor":            true,
+	"user":                true,
+	"cache":               true,
+	"state":               true,
+	"kind":                true,
+	"network_mode":        true,
+	"network":             true,
+	"ip_address":          true,
+	"container_port":      true,
+	"protocol":            true,
+	"restart_policy":      true,
+	"restart_max_retries": true,
+	"privileged":          true,
+}
+
+// labelMapEntry adds static labels to containers matching a name pattern or a
+// docker label selector.
+type labelMapEntry struct {
+	Name     string            `yaml:"name"`
+	Selector map[string]string `yaml:"selector"`
+	Labels   map[string]string `yaml:"labels"`
+}
+
+func (e *labelMapEntry) matches(cName string, dockerLabels map[string]string) bool {
+	if e.Name != "" {
+		ok, _ := path.Match(e.Name, cName)
+		return ok
+	}
+
+	for k, v := range e.Selector {
+		if dockerLabels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// labelMapping is a loaded label map. The label names are the union of the
+// labels of all entries.
+type labelMapping struct {
+	entries []labelMapEntry
+	names   []string
+}
+
+// labelMap maps containers to additional static labels, read from the file
+// given with --labels.map-file.
+type labelMap struct {
+	file string
+	// label names of --labels.docker, they can't be set from the file either
+	reserved map[string]bool
+
+	mu      sync.Mutex
+	mapping *labelMapping
+}
+
+func newLabelMap(file string, reserved []string) (*labelMap, error) {
+	m := &labelMap{file: file, reserved: map[string]bool{}}
+	for _, name := range reserved {
+		m.reserved[name] = true
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// load (re-)reads the label map file. Invalid entries are logged with their
+// line and skipped, on any other error the current mapping is kept.
+func (m *labelMap) load() error {
+	data, err := os.ReadFile(m.file)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("can't parse %s: %w", m.file, err)
+	}
+
+	var nodes []yaml.Node
+	if len(root.Content) > 0 {
+		if err := root.Content[0].Decode(&nodes); err != nil {
+			return fmt.Errorf("can't parse %s: expected a list of entries: %w", m.file, err)
+		}
+	}
+
+	var entries []labelMapEntry
+	names := map[string]bool{}
+	for _, node := range nodes {
+		var entry labelMapEntry
+		if err := node.Decode(&entry); err != nil {
+			log.Errorf("%s line %d: invalid entry: %v", m.file, node.Line, err)
+			continue
+		}
+		if err := entry.validate(m.reserved); err != nil {
+			log.Errorf("%s line %d: invalid entry: %v", m.file, node.Line, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+		for name := range entry.Labels {
+			names[name] = true
+		}
+	}
+
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	m.mu.Lock()
+	m.mapping = &labelMapping{entries: entries, names: sortedNames}
+	m.mu.Unlock()
+
+	log.Infof("loaded %d label map entries from %s", len(entries), m.file)
+	return nil
+}
+
+func (e *labelMapEntry) validate(reserved map[string]bool) error {
+	if (e.Name == "") == (len(e.Selector) == 0) {
+		return fmt.Errorf("exactly one of name and selector is required")
+	}
+	if _, err := path.Match(e.Name, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", e.Name, err)
+	}
+	if len(e.Labels) == 0 {
+		return fmt.Errorf("no labels")
+	}
+	for name := range e.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if reservedLabels[name] || reserved[name] {
+			return fmt.Errorf("label name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+// current returns the mapping in use. A scrape uses the same mapping for all
+// containers, so the label names stay consistent during a reload.
+func (m *labelMap) current() *labelMapping {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mapping
+}
+
+// labels returns the additional label values for a container. Containers
+// without a match get empty values.
+func (m *labelMapping) labels(cName string, dockerLabels map[string]string) []string {
+	matched := map[string]string{}
+	for i := range m.entries {
+		if m.entries[i].matches(cName, dockerLabels) {
+			for k, v := range m.entries[i].Labels {
+				matched[k] = v
+			}
+		}
+	}
+
+	values := make([]string, len(m.names))
+	for i, name := range m.names {
+		values[i] = matched[name]
+	}
+	return values
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// containerLabels holds the label values shared by all metrics of a single
+// container and the descriptors of the scrape matching their names.
+type containerLabels struct {
+	descs  *descSet
+	values []string
+
+	// start time of the container, the created timestamp of its counters,
+	// zero if unknown
+	started time.Time
+}
+
+// name returns the container name.
+func (l *containerLabels) name() string {
+	return l.values[0]
+}
+
+// desc returns the descriptor of a per-container metric.
+func (l *containerLabels) desc(d *metricDesc) *prometheus.Desc {
+	return l.descs.get(d)
+}
+
+// valuesWith returns the container label values followed by the given metric-specific values.
+func (l *containerLabels) valuesWith(values ...string) []string {
+	return append(append(make([]string, 0, len(l.values)+len(values)), l.values...), values...)
+}
+
+// counter returns a counter of the container, with the container's start time
+// as created timestamp if known. The stats counters start at zero with every
+// start of the container.
+func (l *containerLabels) counter(d *metricDesc, value float64, values ...string) prometheus.Metric {
+	if l.started.IsZero() {
+		return prometheus.MustNewConstMetric(l.desc(d), prometheus.CounterValue, value, values...)
+	}
+	return prometheus.MustNewConstMetricWithCreatedTimestamp(l.desc(d), prometheus.CounterValue, value, l.started, values...)
+}
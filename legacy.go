@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	// names of the counters before the _total suffix was added
+	legacyNames = map[string]string{
+		"network_rx_bytes_total":            "network_rx_bytes",
+		"network_tx_bytes_total":            "network_tx_bytes",
+		"network_interface_rx_bytes_total":  "network_interface_rx_bytes",
+		"network_interface_tx_bytes_total":  "network_interface_tx_bytes",
+		"block_io_read_bytes_total":         "block_io_read_bytes",
+		"block_io_write_bytes_total":        "block_io_write_bytes",
+		"block_io_device_read_bytes_total":  "block_io_device_read_bytes",
+		"block_io_device_write_bytes_total": "block_io_device_write_bytes",
+		"host_network_rx_bytes_total":       "host_network_rx_bytes",
+		"host_network_tx_bytes_total":       "host_network_tx_bytes",
+		"host_block_io_read_bytes_total":    "host_block_io_read_bytes",
+		"host_block_io_write_bytes_total":   "host_block_io_write_bytes",
+	}
+
+	// gauges that used to be exposed as counters, a name can't have two
+	// types so the counters get a suffix
+	legacyCounters = map[string]string{
+		"memory_usage_bytes": "memory_usage_bytes_counter",
+		"memory_total_bytes": "memory_total_bytes_counter",
+		"pids_current":       "pids_current_counter",
+	}
+)
+
+// legacyMetrics passes the metrics of the docker collector on to ch and adds
+// the metrics of older releases: renamed counters are exposed under both
+// names, the gauges that used to be counters additionally as counters.
+func legacyMetrics(metrics <-chan prometheus.Metric, ch chan<- prometheus.Metric, descs *descSet, namespace string) {
+	for m := range metrics {
+		ch <- m
+
+		d, ok := descs.metric(m.Desc())
+		if !ok {
+			continue
+		}
+		name, ok := legacyNames[d.name]
+		if !ok {
+			name, ok = legacyCounters[d.name]
+		}
+		if !ok {
+			continue
+		}
+		if legacy, err := convertMetric(m, prometheus.BuildFQName(namespace, "", name), d.help, prometheus.CounterValue); err == nil {
+			ch <- legacy
+		}
+	}
+}
+
+// convertMetric copies a counter or gauge under a new name and type.
+func convertMetric(m prometheus.Metric, name, help string, valueType prometheus.ValueType) (prometheus.Metric, error) {
+	var d dto.Metric
+	if err := m.Write(&d); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(d.GetLabel()))
+	values := make([]string, 0, len(d.GetLabel()))
+	for _, label := range d.GetLabel() {
+		names = append(names, label.GetName())
+		values = append(values, label.GetValue())
+	}
+
+	value := d.GetGauge().GetValue()
+	if d.Counter != nil {
+		value = d.GetCounter().GetValue()
+	}
+
+	return prometheus.NewConstMetric(prometheus.NewDesc(name, help, names, nil), valueType, value, values...)
+}
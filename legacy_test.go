@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestLegacyMetrics(t *testing.T) {
+	cli := &fakeDocker{
+		containers: []types.Container{{ID: "a1", Names: []string{"/web"}, State: "running"}},
+		stats: map[string]string{"a1": `{
+			"memory_stats": {"usage": 1000, "limit": 4000},
+			"networks": {"eth0": {"rx_bytes": 500, "tx_bytes": 100}}
+		}`},
+	}
+	cfg := testConfig()
+	cfg.legacyNames = true
+	c := newDockerCollector(cfg, cli)
+
+	m := collectMetrics(t, c.Collect)
+
+	// the gauge and the counter of older releases
+	if got := m["dex_memory_usage_bytes"]; len(got) != 1 || got[0].Gauge == nil {
+		t.Errorf("dex_memory_usage_bytes = %v, want a gauge", got)
+	}
+	if got := m["dex_memory_usage_bytes_counter"]; len(got) != 1 || got[0].Counter.GetValue() != 1000 {
+		t.Errorf("dex_memory_usage_bytes_counter = %v, want a counter of 1000", got)
+	}
+
+	// renamed counters under both names
+	for _, name := range []string{"dex_network_rx_bytes_total", "dex_network_rx_bytes"} {
+		if len(m[name]) == 0 {
+			t.Errorf("%s is missing", name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/blkiodev"
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CFS period the daemon uses if --cpu-quota is given without --cpu-period
+const defaultCPUPeriod = 100000
+
+var (
+	containerBlkioLimitDesc = newContainerDesc(
+		"container_blkio_limit",
+		"Configured block I/O throttle limit per device, unit is bps (bytes per second) or iops",
+		"device",
+		"op",
+		"unit",
+	)
+	cpuLimitCPUsDesc = newContainerDesc(
+		"cpu_limit_cpus",
+		"Number of CPUs the container is limited to by --cpus, --cpu-quota or --cpuset-cpus",
+	)
+	memoryLimitBytesDesc = newContainerDesc(
+		"memory_limit_bytes",
+		"Memory limit configured with --memory",
+	)
+	memoryReservationBytesDesc = newContainerDesc(
+		"memory_reservation_bytes",
+		"Memory soft limit configured with --memory-reservation",
+	)
+)
+
+func (c *DockerCollector) limitMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	if info.HostConfig == nil {
+		return
+	}
+
+	c.blkioLimitMetrics(ch, info.HostConfig.BlkioDeviceReadBps, "read", "bps", l)
+	c.blkioLimitMetrics(ch, info.HostConfig.BlkioDeviceWriteBps, "write", "bps", l)
+	c.blkioLimitMetrics(ch, info.HostConfig.BlkioDeviceReadIOps, "read", "iops", l)
+	c.blkioLimitMetrics(ch, info.HostConfig.BlkioDeviceWriteIOps, "write", "iops", l)
+
+	if info.HostConfig.Memory > 0 {
+		ch <- prometheus.MustNewConstMetric(l.desc(memoryLimitBytesDesc), prometheus.GaugeValue, float64(info.HostConfig.Memory), l.values...)
+	}
+	if info.HostConfig.MemoryReservation > 0 {
+		ch <- prometheus.MustNewConstMetric(l.desc(memoryReservationBytesDesc), prometheus.GaugeValue, float64(info.HostConfig.MemoryReservation), l.values...)
+	}
+
+	if limit, ok := cpuLimit(info.HostConfig); ok {
+		ch <- prometheus.MustNewConstMetric(l.desc(cpuLimitCPUsDesc), prometheus.GaugeValue, limit, l.values...)
+	}
+}
+
+func (c *DockerCollector) blkioLimitMetrics(ch chan<- prometheus.Metric, devices []*blkiodev.ThrottleDevice, op, unit string, l *containerLabels) {
+	for _, device := range devices {
+		ch <- prometheus.MustNewConstMetric(l.desc(containerBlkioLimitDesc), prometheus.GaugeValue, float64(device.Rate), l.valuesWith(c.devices.nameForPath(device.Path), op, unit)...)
+	}
+}
+
+// cpuLimit returns the number of CPUs a container may use and whether it's
+// limited at all. With both a quota and a cpuset the lower limit applies.
+func cpuLimit(hostConfig *container.HostConfig) (float64, bool) {
+	if hostConfig == nil {
+		return 0, false
+	}
+
+	var limit float64
+	switch {
+	case hostConfig.NanoCPUs > 0:
+		limit = float64(hostConfig.NanoCPUs) / 1e9
+	case hostConfig.CPUQuota > 0:
+		period := hostConfig.CPUPeriod
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		limit = float64(hostConfig.CPUQuota) / float64(period)
+	}
+
+	if cpus, err := cpusetSize(hostConfig.CpusetCpus); err == nil && cpus > 0 {
+		if limit == 0 || float64(cpus) < limit {
+			limit = float64(cpus)
+		}
+	}
+
+	return limit, limit > 0
+}
+
+// cpusetSize returns the number of CPUs in a cpuset list like 0-3,6.
+func cpusetSize(cpuset string) (int, error) {
+	var size int
+	for _, part := range strings.Split(cpuset, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cpuset %q", cpuset)
+		}
+		to, err := strconv.Atoi(last)
+		if err != nil || to < from {
+			return 0, fmt.Errorf("invalid cpuset %q", cpuset)
+		}
+		size += to - from + 1
+	}
+	return size, nil
+}
@@ -2,48 +2,167 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
+	cfg, err := parseConfig()
+	if err != nil {
+		log.Fatalf("can't load config file: %v", err)
+	}
+
+	if cfg.version {
+		printVersion(os.Stdout)
+		return
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	log.SetLevel(cfg.logLevelValue)
+	if cfg.logFormat == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	if cfg.configCheck {
+		log.Info("configuration is valid")
+		return
+	}
+
+	if cfg.inspect {
+		if flag.NArg() != 1 {
+			log.Fatal("usage: dex inspect [flags] <container name or ID>")
+		}
+		if err := runInspect(cfg, flag.Arg(0), os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	reg := prometheus.NewRegistry()
-	reg.MustRegister(newDockerCollector())
+	reg.MustRegister(filterCollector(newBuildInfo(cfg.namespace), cfg.metricsOnlyRegex))
 
-	router := http.NewServeMux()
-	router.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{
-		Registry: reg,
-	}))
+	// the endpoints are collected concurrently by the registry, each within
+	// --docker.timeout, so an unreachable daemon doesn't hold up the others
+	var clients []*dockerClient
+	var pingers []pinger
+	var debugEndpoints []debugEndpoint
+	for _, endpoint := range cfg.endpoints {
+		cli, err := newDockerClientFromConfig(cfg, endpoint)
+		if err != nil {
+			log.Fatalf("can't create docker client: %v", err)
+		}
+		collector := registerEndpoint(cfg, endpoint, cli, reg)
+		clients = append(clients, cli)
+		pingers = append(pingers, cli)
+		debugEndpoints = append(debugEndpoints, debugEndpoint{alias: endpoint.alias, collector: collector})
+	}
+
+	if cfg.dockerMetricsURL != "" {
+		reg.MustRegister(filterCollector(newEngineCollector(cfg.dockerMetricsURL, cfg.dockerMetricsAllowRegex, cfg.namespace), cfg.metricsOnlyRegex))
+	}
+
+	var otlpProvider *sdkmetric.MeterProvider
+	if cfg.otlpEndpoint != "" {
+		provider, err := startOTLPExport(context.Background(), reg, cfg.otlpEndpoint, cfg.otlpInterval)
+		if err != nil {
+			log.Fatalf("can't create OTLP exporter: %v", err)
+		}
+		otlpProvider = provider
+		log.Infof("Exporting metrics via OTLP to %s every %v", cfg.otlpEndpoint, cfg.otlpInterval)
+	}
+
+	if cfg.remoteWriteURL != "" {
+		writer, err := newRemoteWriter(cfg, reg)
+		if err != nil {
+			log.Fatalf("can't create remote writer: %v", err)
+		}
+		reg.MustRegister(writer.samplesSent, writer.samplesFailed, writer.pendingGauge)
+
+		go writer.run(context.Background())
+		log.Infof("Pushing metrics via remote write to %s every %v", cfg.remoteWriteURL, cfg.remoteWriteInterval)
+	}
+
+	if cfg.graphiteAddress != "" {
+		if err := startGraphiteBridge(context.Background(), reg, cfg.graphiteAddress, cfg.graphitePrefix, cfg.graphiteInterval, cfg.graphiteTags); err != nil {
+			log.Fatalf("can't create graphite bridge: %v", err)
+		}
+		log.Infof("Pushing metrics to graphite at %s every %v", cfg.graphiteAddress, cfg.graphiteInterval)
+	}
 
-	serverPort := 8080
+	if cfg.textfilePath != "" {
+		go runTextfileWriter(context.Background(), reg, cfg.textfilePath, cfg.textfileInterval)
+		log.Infof("Writing metrics to %s every %v", cfg.textfilePath, cfg.textfileInterval)
+	}
+
+	if cfg.pushgatewayURL != "" {
+		go runPushgateway(context.Background(), newPusher(cfg, reg), cfg.pushgatewayInterval)
+		log.Infof("Pushing metrics to the pushgateway at %s every %v", cfg.pushgatewayURL, cfg.pushgatewayInterval)
+	}
+
+	router := http.NewServeMux()
+	router.Handle(cfg.webMetricsPath, metricsHandler(reg, cfg.webSoftFail, cfg.namespace))
+	router.Handle("/healthz", healthzHandler(pingers))
+	if cfg.webDebugEndpoints {
+		router.Handle("/debug/containers", debugContainersHandler(debugEndpoints))
+	}
+	if cfg.webMetricsPath != "/" {
+		router.Handle("/", landingPage(cfg.webMetricsPath))
+	}
 
-	if strPort, isSet := os.LookupEnv("DEX_PORT"); isSet {
-		if intPort, err := strconv.Atoi(strPort); err == nil {
-			serverPort = intPort
+	var handler http.Handler = router
+	if cfg.webAuthUsers != "" {
+		users, err := loadUsers(cfg.webAuthUsers)
+		if err != nil {
+			log.Fatalf("can't load users: %v", err)
 		}
+		handler = basicAuth(handler, users)
 	}
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%v", serverPort),
-		Handler:      router,
+		Addr:         cfg.webListenAddress,
+		Handler:      logRequests(handler, cfg.webLogRequests),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 120 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
 
+	if cfg.webTLSCert != "" {
+		cert, err := newCertificate(cfg.webTLSCert, cfg.webTLSKey)
+		if err != nil {
+			log.Fatalf("can't load TLS certificate: %v", err)
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: cert.get,
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := cert.load(); err != nil {
+					log.Error("can't reload TLS certificate, keeping the current one: ", err)
+				}
+			}
+		}()
+	}
+
 	done := make(chan bool)
 
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-quit
@@ -55,14 +174,152 @@ func main() {
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatalf("Could not gracefully shutdown the server: %v\n", err)
 		}
+		if otlpProvider != nil {
+			if err := otlpProvider.Shutdown(ctx); err != nil {
+				log.Error("can't shut down OTLP exporter: ", err)
+			}
+		}
+		for _, cli := range clients {
+			if err := cli.Close(); err != nil {
+				log.Error("can't close docker client: ", err)
+			}
+		}
 		close(done)
 	}()
 
-	log.Info("Server is ready to handle requests at :", serverPort)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Could not listen on %d: %v\n", serverPort, err)
+	// without listen address the metrics are only written or pushed, the
+	// server is never started and its shutdown returns right away
+	if cfg.webListenAddress != "" {
+		log.Infof("Server is ready to handle requests at %s%s", cfg.webListenAddress, cfg.webMetricsPath)
+		if cfg.webTLSCert != "" {
+			// the certificate comes from TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Could not listen on %s: %v\n", cfg.webListenAddress, err)
+		}
 	}
 
 	<-done
 	log.Info("Server stopped")
 }
+
+// registerEndpoint registers the collectors of a docker daemon, their metrics
+// carry the host label if the endpoint has an alias. It returns the docker
+// collector.
+func registerEndpoint(cfg *config, endpoint dockerEndpoint, cli *dockerClient, reg prometheus.Registerer) *DockerCollector {
+	if endpoint.alias != "" {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{hostLabel: endpoint.alias}, reg)
+	}
+
+	collector := setupCollector(cfg, cli)
+
+	reg.MustRegister(filterCollector(collector, cfg.metricsOnlyRegex))
+	reg.MustRegister(filterCollector(cli.apiCalls, cfg.metricsOnlyRegex))
+	reg.MustRegister(filterCollector(collector.scrapeErrors, cfg.metricsOnlyRegex))
+
+	if cfg.statsInterval > 0 {
+		collector.statsCache = newStatsCache()
+		go collector.refreshStats(cfg.statsInterval)
+	}
+	if cfg.statsMode == "stream" {
+		collector.statsStreams = newStatsStreams(cli)
+	}
+
+	// dex_up is 0 until the daemon is reachable, afterwards the docker client
+	// and daemon caches are primed so the first real scrape is fast
+	go func() {
+		if err := cli.waitForDaemon(context.Background()); err == nil {
+			collector.warmUp()
+		}
+	}()
+
+	if cfg.writableLayer && cfg.anyAllowed("container_writable_layer_bytes") {
+		reg.MustRegister(newWritableLayerCollector(cli, cfg.namespace, cfg.writableLayerInterval, cfg.writableLayerBudget))
+	}
+
+	if cfg.size && cfg.anyAllowed("container_rw_size_bytes", "container_rootfs_size_bytes") {
+		reg.MustRegister(filterCollector(newContainerSizeCollector(cli, cfg.namespace, cfg.sizeInterval, cfg.containersAll), cfg.metricsOnlyRegex))
+	}
+
+	if cfg.diskUsage {
+		reg.MustRegister(filterCollector(newDiskUsageCollector(cli, cfg.namespace, cfg.diskUsageInterval), cfg.metricsOnlyRegex))
+	}
+
+	if cfg.swarm {
+		reg.MustRegister(filterCollector(newSwarmCollector(cli, cfg.namespace, cfg.dockerTimeout), cfg.metricsOnlyRegex))
+	}
+
+	// the event-derived collectors share one subscription
+	watcher := newEventsWatcher(cli)
+	state := &stateFile{path: cfg.stateFile, interval: cfg.stateInterval}
+
+	if cfg.stateDuration && cfg.anyAllowed("container_state_duration_seconds_total") {
+		state.states = newStateDurationCollector(cli, cfg.namespace)
+		watcher.add(state.states)
+		reg.MustRegister(state.states)
+	}
+
+	if cfg.events && cfg.anyAllowed("container_events_total") {
+		state.events = newContainerEventsCollector(cli, cfg.namespace, cfg.eventsTTL)
+		watcher.add(state.events)
+		reg.MustRegister(state.events)
+	}
+
+	if len(watcher.listeners) > 0 {
+		if cfg.stateFile != "" {
+			watcher.state = state
+		}
+		go watcher.run()
+	}
+
+	if cfg.mountUsage && cfg.anyAllowed("container_mount_usage_bytes") {
+		reg.MustRegister(newMountUsageCollector(cli, cfg.namespace, cfg.mountUsageInterval, cfg.mountUsageBudget, cfg.mountUsageIncludeNetworkFS))
+	}
+
+	return collector
+}
+
+// setupCollector creates the docker collector and its optional readers.
+func setupCollector(cfg *config, cli dockerAPI) *DockerCollector {
+	collector := newDockerCollector(cfg, cli)
+
+	if cfg.gpu {
+		if gpus, err := newGPUReader(); err != nil {
+			log.Warn("GPU collector disabled, can't initialize NVML: ", err)
+		} else {
+			collector.gpus = gpus
+		}
+	}
+
+	if cfg.connections {
+		if sockets, err := newSocketReader(); err != nil {
+			log.Warn("connection collector disabled, can't read /proc: ", err)
+		} else {
+			collector.sockets = sockets
+		}
+	}
+
+	if cfg.labelsMapFile != "" {
+		reserved := append(dockerLabelNames(cfg.dockerLabels), cfg.optionalLabelNames()...)
+		labelMap, err := newLabelMap(cfg.labelsMapFile, reserved)
+		if err != nil {
+			log.Fatalf("can't load label map: %v", err)
+		}
+		collector.labelMap = labelMap
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := labelMap.load(); err != nil {
+					log.Error("can't reload label map, keeping the current one: ", err)
+				}
+			}
+		}()
+	}
+
+	return collector
+}
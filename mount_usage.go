@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type mountUsage struct {
+	cName       string
+	destination string
+	size        float64
+}
+
+// MountUsageCollector reports the disk usage of bind mounts and volumes of
+// running containers. Like the writable layer collector, the mount sources are
+// walked in the background and the last result is served on scrape.
+type MountUsageCollector struct {
+	cli              *dockerClient
+	desc             *prometheus.Desc
+	interval         time.Duration
+	budget           time.Duration
+	includeNetworkFS bool
+
+	mu    sync.Mutex
+	usage []mountUsage
+}
+
+func newMountUsageCollector(cli *dockerClient, namespace string, interval, budget time.Duration, includeNetworkFS bool) *MountUsageCollector {
+	c := &MountUsageCollector{
+		cli: cli,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_mount_usage_bytes"),
+			"Disk usage of a bind mount or volume of the container in bytes",
+			[]string{"container_name", "destination"},
+			nil,
+		),
+		interval:         interval,
+		budget:           budget,
+		includeNetworkFS: includeNetworkFS,
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *MountUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *MountUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, u := range c.usage {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, u.size, u.cName, u.destination)
+	}
+}
+
+func (c *MountUsageCollector) run() {
+	for {
+		c.refresh()
+		time.Sleep(c.interval)
+	}
+}
+
+func (c *MountUsageCollector) refresh() {
+	containers, err := c.cli.ContainerList(context.Background(), container.ListOptions{})
+	if err != nil {
+		log.Error("can't list containers: ", err)
+		return
+	}
+
+	var usage []mountUsage
+	for _, container := range containers {
+		cName := containerName(container)
+		deadline := time.Now().Add(c.budget)
+
+		for _, m := range container.Mounts {
+			if m.Type != mount.TypeBind && m.Type != mount.TypeVolume {
+				continue
+			}
+			if !c.includeNetworkFS && (m.Type == mount.TypeVolume && m.Driver != "local" || isNetworkFS(m.Source)) {
+				log.Debugf("skipping network filesystem mount %s of container %s", m.Destination, cName)
+				continue
+			}
+
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				log.Warnf("mount usage budget of %v exhausted for container %s, skipping %s", c.budget, cName, m.Destination)
+				continue
+			}
+
+			size, err := walkSize(m.Source, remaining)
+			if errors.Is(err, errWalkBudget) {
+				log.Warnf("mount %s of container %s not fully walked within %v, reporting partial size", m.Destination, cName, c.budget)
+			} else if err != nil {
+				log.Error("can't walk mount source: ", err)
+				continue
+			}
+
+			usage = append(usage, mountUsage{cName: cName, destination: m.Destination, size: float64(size)})
+		}
+	}
+
+	c.mu.Lock()
+	c.usage = usage
+	c.mu.Unlock()
+}
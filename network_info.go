@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	containerNetworkInfoDesc = newContainerDesc(
+		"container_network_info",
+		"Network the container is attached to, value is always 1",
+		"network_mode",
+		"network",
+		"ip_address",
+	)
+	containerPortDesc = newContainerDesc(
+		"container_port",
+		"Port of the container published on the host, value is always 1",
+		"container_port",
+		"host_port",
+		"protocol",
+	)
+)
+
+// networkInfoMetrics reports the networks and published ports of a container from
+// the container list, no inspect call is needed.
+func (c *DockerCollector) networkInfoMetrics(ch chan<- prometheus.Metric, container types.Container, l *containerLabels) {
+	if container.NetworkSettings != nil {
+		mode := container.HostConfig.NetworkMode
+		for name, network := range container.NetworkSettings.Networks {
+			var ip string
+			if network != nil {
+				ip = network.IPAddress
+			}
+			ch <- prometheus.MustNewConstMetric(l.desc(containerNetworkInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(mode, name, ip)...)
+		}
+	}
+
+	// IPv4 and IPv6 bindings of the same port are listed separately
+	seen := map[types.Port]bool{}
+	for _, port := range container.Ports {
+		if port.PublicPort == 0 {
+			continue
+		}
+		key := types.Port{PrivatePort: port.PrivatePort, PublicPort: port.PublicPort, Type: port.Type}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		ch <- prometheus.MustNewConstMetric(l.desc(containerPortDesc), prometheus.GaugeValue, 1,
+			l.valuesWith(strconv.Itoa(int(port.PrivatePort)), strconv.Itoa(int(port.PublicPort)), port.Type)...)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// units announced with # UNIT in the OpenMetrics format, by name suffix
+var metricUnits = []string{"bytes", "seconds", "percent"}
+
+// setUnits sets the unit of the metric families whose names end with one of
+// metricUnits, ignoring the _total suffix of counters. The unit is only
+// written in the OpenMetrics format.
+func setUnits(mfs []*dto.MetricFamily) {
+	for _, mf := range mfs {
+		name := strings.TrimSuffix(mf.GetName(), "_total")
+		for _, unit := range metricUnits {
+			if strings.HasSuffix(name, "_"+unit) {
+				mf.Unit = stringPtr(unit)
+				break
+			}
+		}
+	}
+}
+
+// wantsOpenMetrics reports whether the client accepts the OpenMetrics format
+// and prefers it over the others.
+func wantsOpenMetrics(r *http.Request) bool {
+	return expfmt.NegotiateIncludingOpenMetrics(r.Header).FormatType() == expfmt.TypeOpenMetrics
+}
+
+// writeOpenMetrics writes mfs in the OpenMetrics format with # UNIT lines and
+// _created lines for the counters that have a created timestamp. promhttp
+// can't be used for this, it doesn't enable either.
+func writeOpenMetrics(w http.ResponseWriter, r *http.Request, mfs []*dto.MetricFamily) {
+	format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+	w.Header().Set("Content-Type", string(format))
+
+	var out io.Writer = w
+	if acceptsGzip(r.Header) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	enc := expfmt.NewEncoder(out, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			log.Debug("can't write metrics: ", err)
+			return
+		}
+	}
+	if closer, ok := enc.(expfmt.Closer); ok {
+		// writes the final # EOF line
+		if err := closer.Close(); err != nil {
+			log.Debug("can't write metrics: ", err)
+		}
+	}
+}
+
+// acceptsGzip reports whether the client accepts gzip-encoded responses.
+func acceptsGzip(header http.Header) bool {
+	for _, part := range strings.Split(header.Get("Accept-Encoding"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "gzip" || strings.HasPrefix(part, "gzip;") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// startOTLPExport pushes the metrics of gatherer to an OTLP/HTTP endpoint
+// every interval. Counters become cumulative monotonic sums, labels become
+// datapoint attributes. The returned provider has to be shut down on exit to
+// flush the last export.
+func startOTLPExport(ctx context.Context, gatherer prometheus.Gatherer, endpoint string, interval time.Duration) (*sdkmetric.MeterProvider, error) {
+	exporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(promBridge.NewMetricProducer(promBridge.WithGatherer(gatherer))),
+	)
+
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("dex"))
+
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(res),
+	), nil
+}
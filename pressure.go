@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	pressureCPUWaitingSecondsTotalDesc = newContainerDesc(
+		"pressure_cpu_waiting_seconds_total",
+		"Time tasks of the container were stalled waiting for CPU (PSI), some or all of them (kind)",
+		"kind",
+	)
+	pressureMemoryWaitingSecondsTotalDesc = newContainerDesc(
+		"pressure_memory_waiting_seconds_total",
+		"Time tasks of the container were stalled waiting for memory (PSI), some or all of them (kind)",
+		"kind",
+	)
+	pressureIOWaitingSecondsTotalDesc = newContainerDesc(
+		"pressure_io_waiting_seconds_total",
+		"Time tasks of the container were stalled waiting for I/O (PSI), some or all of them (kind)",
+		"kind",
+	)
+)
+
+// pressure files of a cgroup v2 and their metrics
+var pressureFiles = []struct {
+	file string
+	desc *metricDesc
+}{
+	{"cpu.pressure", pressureCPUWaitingSecondsTotalDesc},
+	{"memory.pressure", pressureMemoryWaitingSecondsTotalDesc},
+	{"io.pressure", pressureIOWaitingSecondsTotalDesc},
+}
+
+// pressureMetrics reports the pressure stall information of a running
+// container from its cgroup. The stats API doesn't provide it.
+func (c *DockerCollector) pressureMetrics(ch chan<- prometheus.Metric, cgroups *cgroupReader, info *types.ContainerJSON, l *containerLabels) {
+	dir := filepath.Join(cgroups.root, cgroups.path(info))
+
+	for _, f := range pressureFiles {
+		totals, err := readPressure(filepath.Join(dir, f.file))
+		if err != nil {
+			// kernels without CONFIG_PSI or booted with psi=0 have no pressure files
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Debugf("can't read %s of container %s: %v", f.file, l.name(), err)
+			}
+			continue
+		}
+
+		for kind, total := range totals {
+			ch <- l.counter(f.desc, total, l.valuesWith(kind)...)
+		}
+	}
+}
+
+// readPressure reads the total stall time in seconds per kind (some, full)
+// of a pressure file.
+func readPressure(path string) (map[string]float64, error) {
+	totals := map[string]float64{}
+	err := readLines(path, func(fields []string) {
+		// some avg10=0.00 avg60=0.00 avg300=0.00 total=12345
+		for _, field := range fields[1:] {
+			raw, ok := strings.CutPrefix(field, "total=")
+			if !ok {
+				continue
+			}
+			if usec, err := strconv.ParseUint(raw, 10, 64); err == nil {
+				totals[fields[0]] = float64(usec) / 1e6
+			}
+		}
+	})
+	return totals, err
+}
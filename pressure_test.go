@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
+
+func TestPressureDaemonStartedLater(t *testing.T) {
+	ctr := types.Container{ID: cgroupTestID, Names: []string{"/web"}, State: "running"}
+
+	tests := []struct {
+		root string
+		// reported once the daemon info is available
+		want bool
+	}{
+		{root: "testdata/cgroup/v2", want: true},
+		// no pressure stall information on cgroup v1
+		{root: "testdata/cgroup/v1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.root, func(t *testing.T) {
+			cli := &fakeDocker{
+				containers: []types.Container{ctr},
+				stats:      map[string]string{ctr.ID: statsCgroupV2},
+				inspects: map[string]types.ContainerJSON{ctr.ID: {
+					ContainerJSONBase: &types.ContainerJSONBase{ID: ctr.ID, Name: "/web", HostConfig: &container.HostConfig{}},
+				}},
+				infoErr: errors.New("daemon not running"),
+			}
+			cfg := testConfig()
+			cfg.pressure = true
+			cfg.statsCgroupRoot = tt.root
+			c := newDockerCollector(cfg, cli)
+
+			m := collectMetrics(t, c.Collect)
+			if _, ok := m.get("dex_pressure_memory_waiting_seconds_total"); ok {
+				t.Error("pressure reported without the daemon's cgroup driver")
+			}
+
+			cli.infoErr = nil
+			cli.info = system.Info{CgroupDriver: "systemd"}
+			c.infoFailed = time.Now().Add(-infoRetryInterval)
+
+			m = collectMetrics(t, c.Collect)
+			got, ok := m.get("dex_pressure_memory_waiting_seconds_total", "container_name", "web", "kind", "some")
+			if ok != tt.want {
+				t.Fatalf("pressure reported: %t, want %t", ok, tt.want)
+			}
+			if ok && got != 1.234567 {
+				t.Errorf("dex_pressure_memory_waiting_seconds_total = %v, want 1.234567", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	containerPortReachableDesc = newContainerDesc(
+		"container_port_reachable",
+		"1 if a TCP connect to the published port succeeded, 0 otherwise",
+		"host_port",
+	)
+	containerPortConnectDurationSecondsDesc = newContainerDesc(
+		"container_port_connect_duration_seconds",
+		"Duration of the TCP connect to the published port in seconds",
+		"host_port",
+	)
+)
+
+// container label enabling the port probe
+const probeLabel = "dex.probe"
+
+// portProber probes the published TCP ports of containers labeled dex.probe=true.
+type portProber struct {
+	// address used for ports published on all interfaces
+	address string
+	// limit of a single connect
+	timeout time.Duration
+	// limit of all probes of a scrape
+	budget time.Duration
+}
+
+func (c *DockerCollector) probeMetrics(ch chan<- prometheus.Metric, container types.Container, deadline time.Time, l *containerLabels) {
+	if container.Labels[probeLabel] != "true" {
+		return
+	}
+
+	probed := map[uint16]bool{}
+	for _, port := range container.Ports {
+		// IPv4 and IPv6 bindings of the same port are listed separately
+		if port.Type != "tcp" || port.PublicPort == 0 || probed[port.PublicPort] {
+			continue
+		}
+		probed[port.PublicPort] = true
+
+		timeout := min(c.prober.timeout, time.Until(deadline))
+		if timeout <= 0 {
+			log.Debugf("probe budget exhausted, skipping port %d of container %s", port.PublicPort, l.name())
+			continue
+		}
+
+		host := port.IP
+		if host == "" || net.ParseIP(host).IsUnspecified() {
+			host = c.prober.address
+		}
+		hostPort := strconv.Itoa(int(port.PublicPort))
+
+		var reachable float64
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, hostPort), timeout)
+		elapsed := time.Since(start)
+		if err == nil {
+			conn.Close()
+			reachable = 1
+		} else {
+			log.Debugf("can't connect to port %s of container %s: %v", hostPort, l.name(), err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(l.desc(containerPortReachableDesc), prometheus.GaugeValue, reachable, l.valuesWith(hostPort)...)
+
+		if reachable == 1 {
+			ch <- prometheus.MustNewConstMetric(l.desc(containerPortConnectDurationSecondsDesc), prometheus.GaugeValue, elapsed.Seconds(), l.valuesWith(hostPort)...)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	log "github.com/sirupsen/logrus"
+)
+
+// newPusher creates the Pushgateway client of --pushgateway.url with the job
+// and the key=value pairs of --pushgateway.grouping as grouping key.
+func newPusher(cfg *config, gatherer prometheus.Gatherer) *push.Pusher {
+	pusher := push.New(cfg.pushgatewayURL, cfg.pushgatewayJob).Gatherer(gatherer)
+	for _, pair := range cfg.pushgatewayGrouping {
+		name, value, _ := strings.Cut(pair, "=")
+		pusher = pusher.Grouping(name, value)
+	}
+	return pusher
+}
+
+// runPushgateway pushes the metrics every interval. Each push replaces all
+// metrics of the grouping key, so metrics of removed containers disappear.
+func runPushgateway(ctx context.Context, pusher *push.Pusher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pushCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := pusher.PushContext(pushCtx); err != nil {
+				log.Error("can't push metrics to the pushgateway: ", err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// maximum number of batches kept in memory while the endpoint is not reachable
+	remoteWriteMaxPending = 60
+
+	remoteWriteAttempts = 3
+	remoteWriteTimeout  = 30 * time.Second
+)
+
+type remoteWriteBatch struct {
+	body    []byte
+	samples int
+}
+
+// remoteWriter gathers metrics on an interval and sends them to a Prometheus
+// remote write endpoint. Batches that can't be sent are kept in memory and
+// retried on the next interval.
+type remoteWriter struct {
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	interval    time.Duration
+	gatherer    prometheus.Gatherer
+	client      *http.Client
+
+	samplesSent   prometheus.Counter
+	samplesFailed prometheus.Counter
+	pendingGauge  prometheus.Gauge
+
+	pending []remoteWriteBatch
+}
+
+func newRemoteWriter(cfg *config, gatherer prometheus.Gatherer) (*remoteWriter, error) {
+	w := &remoteWriter{
+		url:      cfg.remoteWriteURL,
+		username: cfg.remoteWriteUsername,
+		password: cfg.remoteWritePassword,
+		interval: cfg.remoteWriteInterval,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: remoteWriteTimeout},
+
+		samplesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "remote_write_samples_sent_total",
+			Help:      "Number of samples successfully sent via remote write",
+		}),
+		samplesFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "remote_write_samples_failed_total",
+			Help:      "Number of samples dropped because they couldn't be sent via remote write",
+		}),
+		pendingGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Name:      "remote_write_pending_batches",
+			Help:      "Number of batches waiting to be sent via remote write",
+		}),
+	}
+
+	if cfg.remoteWriteBearerTokenFile != "" {
+		token, err := os.ReadFile(cfg.remoteWriteBearerTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		w.bearerToken = strings.TrimSpace(string(token))
+	}
+
+	return w, nil
+}
+
+func (w *remoteWriter) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.gather()
+			w.flush(ctx)
+		}
+	}
+}
+
+func (w *remoteWriter) gather() {
+	mfs, err := w.gatherer.Gather()
+	if err != nil {
+		log.Error("can't gather metrics for remote write: ", err)
+	}
+	if len(mfs) == 0 {
+		return
+	}
+
+	body, samples := encodeWriteRequest(mfs, time.Now())
+	w.pending = append(w.pending, remoteWriteBatch{body: snappy.Encode(nil, body), samples: samples})
+
+	if len(w.pending) > remoteWriteMaxPending {
+		dropped := w.pending[0]
+		w.pending = w.pending[1:]
+		w.samplesFailed.Add(float64(dropped.samples))
+		log.Warnf("remote write buffer full, dropping %d samples", dropped.samples)
+	}
+	w.pendingGauge.Set(float64(len(w.pending)))
+}
+
+// flush sends all pending batches in order. It stops at the first batch that
+// failed with a retryable error, so it's sent on the next interval.
+func (w *remoteWriter) flush(ctx context.Context) {
+	for len(w.pending) > 0 {
+		batch := w.pending[0]
+
+		retry, err := w.sendWithRetry(ctx, batch.body)
+		if err != nil && retry {
+			log.Error("can't send remote write batch, retrying next interval: ", err)
+			break
+		}
+
+		if err != nil {
+			log.Error("remote write batch rejected, dropping it: ", err)
+			w.samplesFailed.Add(float64(batch.samples))
+		} else {
+			w.samplesSent.Add(float64(batch.samples))
+		}
+		w.pending = w.pending[1:]
+	}
+	w.pendingGauge.Set(float64(len(w.pending)))
+}
+
+func (w *remoteWriter) sendWithRetry(ctx context.Context, body []byte) (retry bool, err error) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		retry, err = w.send(ctx, body)
+		if err == nil || !retry || attempt == remoteWriteAttempts {
+			return retry, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send posts a single batch, retry is true for errors worth retrying
+// (network errors, 5xx and 429 responses).
+func (w *remoteWriter) send(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "dex")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	if w.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.bearerToken)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// encodeWriteRequest encodes metric families as a remote write WriteRequest
+// protobuf message. Only counters, gauges and untyped metrics are encoded.
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(mfs []*dto.MetricFamily, now time.Time) ([]byte, int) {
+	var req []byte
+	var samples int
+	timestamp := now.UnixMilli()
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			labels := append([]*dto.LabelPair{{Name: stringPtr("__name__"), Value: mf.Name}}, m.GetLabel()...)
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+
+			var ts []byte
+			for _, l := range labels {
+				var label []byte
+				label = protowire.AppendTag(label, 1, protowire.BytesType)
+				label = protowire.AppendString(label, l.GetName())
+				label = protowire.AppendTag(label, 2, protowire.BytesType)
+				label = protowire.AppendString(label, l.GetValue())
+
+				ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+				ts = protowire.AppendBytes(ts, label)
+			}
+
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(timestamp))
+
+			ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+
+			req = protowire.AppendTag(req, 1, protowire.BytesType)
+			req = protowire.AppendBytes(req, ts)
+			samples++
+		}
+	}
+
+	return req, samples
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	containerPrivilegedDesc = newContainerDesc(
+		"container_privileged",
+		"1 if docker container is privileged, 0 otherwise",
+	)
+	containerReadonlyRootfsDesc = newContainerDesc(
+		"container_readonly_rootfs",
+		"1 if docker container has a read-only root filesystem, 0 otherwise",
+	)
+	containerSecurityInfoDesc = newContainerDesc(
+		"container_security_info",
+		"Effective seccomp and AppArmor profiles of the container",
+		"seccomp",
+		"apparmor",
+	)
+	containerUserInfoDesc = newContainerDesc(
+		"container_user_info",
+		"User the container runs as, empty means the image default (usually root)",
+		"user",
+	)
+	containerRunsAsRootDesc = newContainerDesc(
+		"container_runs_as_root",
+		"1 if docker container runs as root, 0 otherwise",
+	)
+	containerCapabilityInfoDesc = newContainerDesc(
+		"container_capability_info",
+		"Capabilities added to or dropped from the container, action is add, drop, add_all or drop_all",
+		"capability",
+		"action",
+	)
+)
+
+func (c *DockerCollector) securityMetrics(ctx context.Context, ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	if info.HostConfig == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerPrivilegedDesc), prometheus.GaugeValue, boolToFloat(info.HostConfig.Privileged), l.values...)
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerReadonlyRootfsDesc), prometheus.GaugeValue, boolToFloat(info.HostConfig.ReadonlyRootfs), l.values...)
+
+	c.capabilityMetrics(ch, info.HostConfig.CapAdd, "add", l)
+	c.capabilityMetrics(ch, info.HostConfig.CapDrop, "drop", l)
+
+	seccomp, apparmor := c.securityProfiles(ctx, info)
+	ch <- prometheus.MustNewConstMetric(l.desc(containerSecurityInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(seccomp, apparmor)...)
+
+	if info.Config != nil {
+		c.userMetrics(ch, info.Config.User, l)
+	}
+}
+
+func (c *DockerCollector) userMetrics(ch chan<- prometheus.Metric, user string, l *containerLabels) {
+	ch <- prometheus.MustNewConstMetric(l.desc(containerUserInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(user)...)
+
+	// user may be "name", "uid", "name:group" or "uid:gid"
+	name, _, _ := strings.Cut(user, ":")
+	isRoot := name == "" || name == "root" || name == "0"
+
+	ch <- prometheus.MustNewConstMetric(l.desc(containerRunsAsRootDesc), prometheus.GaugeValue, boolToFloat(isRoot), l.values...)
+}
+
+// securityProfiles returns the effective seccomp and AppArmor profiles of a
+// container. Profiles are "default", "unconfined", "disabled" (not supported by
+// the daemon), "custom" (inline seccomp profile) or the profile name.
+func (c *DockerCollector) securityProfiles(ctx context.Context, info *types.ContainerJSON) (seccomp, apparmor string) {
+	seccomp, apparmor = "disabled", "disabled"
+
+	if daemon, err := c.daemonInfo(ctx); err != nil {
+		c.errors.log(info.ID, strings.TrimPrefix(info.Name, "/"), "can't get docker info", err)
+	} else {
+		for _, opt := range daemon.SecurityOptions {
+			// name=seccomp,profile=builtin
+			switch {
+			case strings.HasPrefix(opt, "name=seccomp"):
+				seccomp = "default"
+			case strings.HasPrefix(opt, "name=apparmor"):
+				apparmor = "default"
+			}
+		}
+	}
+
+	for _, opt := range info.HostConfig.SecurityOpt {
+		// seccomp=unconfined, older daemons also accept seccomp:unconfined
+		key, value, found := strings.Cut(opt, "=")
+		if !found {
+			key, value, _ = strings.Cut(opt, ":")
+		}
+
+		switch key {
+		case "seccomp":
+			switch {
+			case value == "unconfined":
+				seccomp = "unconfined"
+			case strings.HasPrefix(strings.TrimSpace(value), "{"):
+				// the docker cli sends the content of the profile file, not its name
+				seccomp = "custom"
+			case value != "":
+				seccomp = value
+			}
+		case "apparmor":
+			if value != "" {
+				apparmor = value
+			}
+		}
+	}
+
+	if info.HostConfig.Privileged {
+		seccomp, apparmor = "unconfined", "unconfined"
+	}
+
+	// profile applied by the daemon, empty if AppArmor is not enabled
+	if info.AppArmorProfile != "" {
+		apparmor = info.AppArmorProfile
+		if apparmor == "docker-default" {
+			apparmor = "default"
+		}
+	}
+
+	return seccomp, apparmor
+}
+
+func (c *DockerCollector) capabilityMetrics(ch chan<- prometheus.Metric, capabilities []string, action string, l *containerLabels) {
+	seen := map[string]bool{}
+	for _, capability := range capabilities {
+		// docker accepts "net_admin", "NET_ADMIN" and "CAP_NET_ADMIN"
+		capability = strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if seen[capability] {
+			continue
+		}
+		seen[capability] = true
+
+		capAction := action
+		if capability == "ALL" {
+			capAction = action + "_all"
+		}
+
+		ch <- prometheus.MustNewConstMetric(l.desc(containerCapabilityInfoDesc), prometheus.GaugeValue, 1, l.valuesWith(capability, capAction)...)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+type containerStates struct {
+	name   string
+	state  string
+	since  time.Time
+	totals map[string]time.Duration
+}
+
+// StateDurationCollector accumulates the wall time containers spend in each
+// state. Transitions are taken from the events of an eventsWatcher, the state
+// of each container is initialized from the container list on startup and
+// after the stream was interrupted. With a state file the counters survive
+// restarts of dex.
+type StateDurationCollector struct {
+	cli  *dockerClient
+	desc *prometheus.Desc
+
+	mu         sync.Mutex
+	containers map[string]*containerStates
+}
+
+func newStateDurationCollector(cli *dockerClient, namespace string) *StateDurationCollector {
+	return &StateDurationCollector{
+		cli: cli,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_state_duration_seconds_total"),
+			"Wall time the container spent in each state in seconds",
+			[]string{"container_name", "state"},
+			nil,
+		),
+		containers: map[string]*containerStates{},
+	}
+}
+
+func (c *StateDurationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *StateDurationCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, states := range c.containers {
+		for state, total := range states.totals {
+			if state == states.state {
+				total += now.Sub(states.since)
+			}
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, total.Seconds(), states.name, state)
+		}
+	}
+}
+
+// sync sets the state of all containers from the container list.
+func (c *StateDurationCollector) sync(containers []types.Container, at time.Time) {
+	ids := map[string]bool{}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, container := range containers {
+		ids[container.ID] = true
+		c.transition(container.ID, containerName(container), container.State, at)
+	}
+
+	for id := range c.containers {
+		if !ids[id] {
+			delete(c.containers, id)
+		}
+	}
+}
+
+func (c *StateDurationCollector) handle(msg events.Message) {
+	at := time.Unix(0, msg.TimeNano)
+
+	switch msg.Action {
+	case events.ActionDestroy:
+		c.mu.Lock()
+		delete(c.containers, msg.Actor.ID)
+		c.mu.Unlock()
+		return
+	case events.ActionCreate, events.ActionStart, events.ActionDie, events.ActionRestart,
+		events.ActionPause, events.ActionUnPause, events.ActionRename:
+	default:
+		return
+	}
+
+	// the event doesn't tell whether a dead container is restarted, the state does
+	info, err := c.cli.ContainerInspect(context.Background(), msg.Actor.ID)
+	if err != nil {
+		log.Debug("can't inspect container: ", err)
+		return
+	}
+	if info.State == nil {
+		return
+	}
+	var networks map[string]*network.EndpointSettings
+	if info.NetworkSettings != nil {
+		networks = info.NetworkSettings.Networks
+	}
+	var labels map[string]string
+	if info.Config != nil {
+		labels = info.Config.Labels
+	}
+	if !c.cli.filter.selects(strings.TrimPrefix(info.Name, "/"), labels, networks) {
+		return
+	}
+
+	c.mu.Lock()
+	c.transition(msg.Actor.ID, strings.TrimPrefix(info.Name, "/"), info.State.Status, at)
+	c.mu.Unlock()
+}
+
+// replay applies an event without inspecting the container, the state is
+// derived from the action.
+func (c *StateDurationCollector) replay(msg events.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if msg.Action == events.ActionDestroy {
+		delete(c.containers, msg.Actor.ID)
+	} else if state, ok := actionStates[msg.Action]; ok {
+		c.transition(msg.Actor.ID, msg.Actor.Attributes["name"], state, time.Unix(0, msg.TimeNano))
+	}
+}
+
+// transition moves a container to state at the given time, the caller must
+// hold the lock.
+func (c *StateDurationCollector) transition(id, name, state string, at time.Time) {
+	states, ok := c.containers[id]
+	if !ok {
+		c.containers[id] = &containerStates{
+			name:   name,
+			state:  state,
+			since:  at,
+			totals: map[string]time.Duration{state: 0},
+		}
+		return
+	}
+
+	states.name = name
+	if states.state == state {
+		return
+	}
+	// events received while syncing are older than the synced state
+	if at.Before(states.since) {
+		at = states.since
+	}
+
+	states.totals[states.state] += at.Sub(states.since)
+	if _, ok := states.totals[state]; !ok {
+		states.totals[state] = 0
+	}
+	states.state = state
+	states.since = at
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	log "github.com/sirupsen/logrus"
+)
+
+// version of the state file format, files of other versions are discarded
+const stateFileVersion = 1
+
+// state of a container derived from an event, used when replaying events
+// the current state can't be inspected for anymore
+var actionStates = map[events.Action]string{
+	events.ActionCreate:  "created",
+	events.ActionStart:   "running",
+	events.ActionRestart: "running",
+	events.ActionUnPause: "running",
+	events.ActionPause:   "paused",
+	events.ActionDie:     "exited",
+}
+
+type checkpoint struct {
+	Version    int                            `json:"version"`
+	Time       time.Time                      `json:"time"`
+	Containers map[string]checkpointContainer `json:"containers"`
+	// counters of the events collector by container ID, files written
+	// before they were checkpointed don't have them
+	Events map[string]checkpointEvents `json:"events,omitempty"`
+}
+
+type checkpointContainer struct {
+	Name   string             `json:"name"`
+	State  string             `json:"state"`
+	Since  time.Time          `json:"since"`
+	Totals map[string]float64 `json:"totals"`
+}
+
+type checkpointEvents struct {
+	Name    string             `json:"name"`
+	Counts  map[string]float64 `json:"counts"`
+	Removed time.Time          `json:"removed"`
+}
+
+// stateFile checkpoints the counters of the event-derived collectors, either
+// of them may be nil.
+type stateFile struct {
+	path     string
+	interval time.Duration
+
+	states *StateDurationCollector
+	events *ContainerEventsCollector
+}
+
+// restore loads the counters from the state file and replays the events
+// between the checkpoint and now to the listeners.
+func (f *stateFile) restore(cli *dockerClient, listeners []eventsListener) {
+	cp, err := readCheckpoint(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Warn("discarding state file: ", err)
+		return
+	}
+
+	if f.states != nil {
+		f.states.mu.Lock()
+		for id, container := range cp.Containers {
+			totals := map[string]time.Duration{}
+			for state, seconds := range container.Totals {
+				totals[state] = time.Duration(seconds * float64(time.Second))
+			}
+			if _, ok := totals[container.State]; !ok {
+				totals[container.State] = 0
+			}
+			f.states.containers[id] = &containerStates{
+				name:   container.Name,
+				state:  container.State,
+				since:  container.Since,
+				totals: totals,
+			}
+		}
+		f.states.mu.Unlock()
+	}
+
+	if f.events != nil {
+		f.events.mu.Lock()
+		for id, container := range cp.Events {
+			counts := map[events.Action]float64{}
+			for action, count := range container.Counts {
+				counts[events.Action(action)] = count
+			}
+			f.events.containers[id] = &containerEventCounts{
+				name:    container.Name,
+				counts:  counts,
+				removed: container.Removed,
+			}
+		}
+		f.events.mu.Unlock()
+	}
+
+	if err := replay(cli, listeners, cp.Time, time.Now()); err != nil {
+		log.Warn("can't replay events since the last checkpoint, the gap is attributed to the checkpointed states: ", err)
+	}
+	log.Infof("restored state of %d containers from %s", max(len(cp.Containers), len(cp.Events)), f.path)
+}
+
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if cp.Version != stateFileVersion {
+		return nil, fmt.Errorf("unsupported version %d", cp.Version)
+	}
+
+	return &cp, nil
+}
+
+// replay passes the container events between since and until to the
+// listeners.
+func replay(cli *dockerClient, listeners []eventsListener, since, until time.Time) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, errs := cli.Events(ctx, types.EventsOptions{
+		Since:   eventsTimestamp(since),
+		Until:   eventsTimestamp(until),
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+	})
+
+	for {
+		select {
+		case msg := <-messages:
+			for _, l := range listeners {
+				l.replay(msg)
+			}
+		case err := <-errs:
+			// the daemon closes the stream after until
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func eventsTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func (f *stateFile) checkpoints() {
+	for {
+		time.Sleep(f.interval)
+		if err := f.save(); err != nil {
+			log.Error("can't write state file: ", err)
+		}
+	}
+}
+
+// save writes the counters to the state file, replacing it atomically.
+func (f *stateFile) save() error {
+	data, err := json.Marshal(f.snapshot())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f *stateFile) snapshot() checkpoint {
+	cp := checkpoint{
+		Version:    stateFileVersion,
+		Containers: map[string]checkpointContainer{},
+	}
+
+	// the replay starts at the checkpoint's time, the events counted before
+	// it must not be counted again
+	if f.states != nil {
+		f.states.mu.Lock()
+		defer f.states.mu.Unlock()
+	}
+	if f.events != nil {
+		f.events.mu.Lock()
+		defer f.events.mu.Unlock()
+	}
+	cp.Time = time.Now()
+
+	if f.states != nil {
+		for id, states := range f.states.containers {
+			totals := map[string]float64{}
+			for state, total := range states.totals {
+				totals[state] = total.Seconds()
+			}
+			cp.Containers[id] = checkpointContainer{
+				Name:   states.name,
+				State:  states.state,
+				Since:  states.since,
+				Totals: totals,
+			}
+		}
+	}
+
+	if f.events != nil {
+		cp.Events = map[string]checkpointEvents{}
+		for id, container := range f.events.containers {
+			counts := map[string]float64{}
+			for action, count := range container.counts {
+				counts[string(action)] = count
+			}
+			cp.Events[id] = checkpointEvents{
+				Name:    container.name,
+				Counts:  counts,
+				Removed: container.removed,
+			}
+		}
+	}
+
+	return cp
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	statsAgeSecondsDesc = newDesc(
+		"stats_age_seconds",
+		"Time since the cached container stats were refreshed",
+	)
+)
+
+// errStatsNotCached is returned for containers started after the last refresh of the stats cache
+var errStatsNotCached = errors.New("no cached stats")
+
+// statsCache holds the stats of all running containers, refreshed in the
+// background every --stats.interval. Scrapes serve the cached stats instead of
+// waiting for the daemon.
+type statsCache struct {
+	mu      sync.Mutex
+	stats   map[string]*types.StatsJSON
+	updated time.Time
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{stats: map[string]*types.StatsJSON{}}
+}
+
+func (s *statsCache) get(id string) (*types.StatsJSON, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[id]
+	if !ok {
+		return nil, errStatsNotCached
+	}
+	return stats, nil
+}
+
+// replace swaps in the stats of a refresh, containers that are gone are dropped.
+func (s *statsCache) replace(stats map[string]*types.StatsJSON) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats = stats
+	s.updated = time.Now()
+}
+
+// prune drops the stats of all containers not in ids, so containers removed
+// since the last refresh aren't served until the next one.
+func (s *statsCache) prune(ids map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.stats {
+		if !ids[id] {
+			delete(s.stats, id)
+		}
+	}
+}
+
+func (s *statsCache) collect(ch chan<- prometheus.Metric, descs *descSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.updated.IsZero() {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(descs.get(statsAgeSecondsDesc), prometheus.GaugeValue, time.Since(s.updated).Seconds())
+}
+
+// refreshStats keeps the stats cache up to date.
+func (c *DockerCollector) refreshStats(interval time.Duration) {
+	for {
+		start := time.Now()
+		c.refreshStatsOnce()
+		log.Debugf("stats cache refreshed in %v", time.Since(start))
+
+		time.Sleep(interval)
+	}
+}
+
+func (c *DockerCollector) refreshStatsOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.dockerTimeout)
+	defer cancel()
+
+	// scrapes report the daemon's failures while it's down
+	if c.daemon.skip() {
+		return
+	}
+
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		if client.IsErrConnectionFailed(err) {
+			c.daemon.failure(err)
+		} else {
+			log.Error("can't list containers: ", err)
+		}
+		c.scrapeErrors.WithLabelValues("container_list", "").Inc()
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	stats := map[string]*types.StatsJSON{}
+	for _, container := range containers {
+		wg.Add(1)
+		go func(container types.Container) {
+			defer wg.Done()
+
+			containerStats, err := c.fetchStats(ctx, container)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			stats[container.ID] = containerStats
+			mu.Unlock()
+		}(container)
+	}
+	wg.Wait()
+
+	c.statsCache.replace(stats)
+}
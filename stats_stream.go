@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	exporterStatsStreamsActiveDesc = newDesc(
+		"exporter_stats_streams_active",
+		"Number of open stats streams of --stats.mode=stream",
+	)
+)
+
+// statsStreams keeps a stats stream open for every running container with
+// --stats.mode=stream, scrapes read the latest sample from memory instead of
+// waiting for the daemon. Only the latest sample of each container is kept.
+type statsStreams struct {
+	cli dockerAPI
+
+	mu      sync.Mutex
+	streams map[string]*statsStream
+}
+
+type statsStream struct {
+	cancel context.CancelFunc
+	latest *types.StatsJSON
+}
+
+func newStatsStreams(cli dockerAPI) *statsStreams {
+	return &statsStreams{
+		cli:     cli,
+		streams: map[string]*statsStream{},
+	}
+}
+
+// get returns the latest sample of a container, errStatsNotCached until the
+// stream delivered one.
+func (s *statsStreams) get(id string) (*types.StatsJSON, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, ok := s.streams[id]
+	if !ok || stream.latest == nil {
+		return nil, errStatsNotCached
+	}
+	return stream.latest, nil
+}
+
+// sync starts the streams of the running containers without one and stops the
+// streams of all other containers. Streams that broke are restarted by the
+// next sync if the container is still running.
+func (s *statsStreams) sync(running map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, stream := range s.streams {
+		if _, ok := running[id]; !ok {
+			stream.cancel()
+			delete(s.streams, id)
+		}
+	}
+
+	for id, cName := range running {
+		if _, ok := s.streams[id]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &statsStream{cancel: cancel}
+		s.streams[id] = stream
+		go s.follow(ctx, id, cName, stream)
+	}
+}
+
+// follow decodes the samples of a stream until it ends.
+func (s *statsStreams) follow(ctx context.Context, id, cName string, stream *statsStream) {
+	defer s.remove(id, stream)
+
+	stats, err := s.cli.ContainerStats(ctx, id, true)
+	if err != nil {
+		log.WithField("container", cName).Debug("can't open stats stream: ", err)
+		return
+	}
+	defer stats.Body.Close()
+
+	decoder := json.NewDecoder(stats.Body)
+	for {
+		var sample types.StatsJSON
+		if err := decoder.Decode(&sample); err != nil {
+			if ctx.Err() == nil {
+				log.WithField("container", cName).Debug("stats stream ended: ", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		stream.latest = &sample
+		s.mu.Unlock()
+	}
+}
+
+// remove drops a stream that ended, unless it was replaced already.
+func (s *statsStreams) remove(id string, stream *statsStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream.cancel()
+	if s.streams[id] == stream {
+		delete(s.streams, id)
+	}
+}
+
+func (s *statsStreams) collect(ch chan<- prometheus.Metric, descs *descSet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(descs.get(exporterStatsStreamsActiveDesc), prometheus.GaugeValue, float64(len(s.streams)))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// SwarmCollector exposes swarm service metrics. Services can only be listed
+// on manager nodes, on workers the collector reports nothing.
+type SwarmCollector struct {
+	cli  *dockerClient
+	desc *prometheus.Desc
+
+	// of the service list call, --docker.timeout
+	timeout time.Duration
+}
+
+func newSwarmCollector(cli *dockerClient, namespace string, timeout time.Duration) *SwarmCollector {
+	return &SwarmCollector{
+		cli:     cli,
+		timeout: timeout,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "swarm_service_update_state"),
+			"Current state of the service's last update (updating, paused, completed, rollback_started, rollback_paused, rollback_completed)",
+			[]string{"service", "state"},
+			nil,
+		),
+	}
+}
+
+func (c *SwarmCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *SwarmCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	services, err := c.cli.ServiceList(ctx, types.ServiceListOptions{})
+	if errdefs.IsUnavailable(err) {
+		// not a manager node (or not part of a swarm)
+		log.Debug("can't list swarm services: ", err)
+		return
+	}
+	if err != nil {
+		log.Error("can't list swarm services: ", err)
+		return
+	}
+
+	for _, service := range services {
+		c.updateMetrics(ch, service)
+	}
+}
+
+func (c *SwarmCollector) updateMetrics(ch chan<- prometheus.Metric, service swarm.Service) {
+	// services that were never updated have no update status
+	if service.UpdateStatus == nil || service.UpdateStatus.State == "" {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1, service.Spec.Name, string(service.UpdateStatus.State))
+}
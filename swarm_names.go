@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// labels set by swarm on task containers
+const (
+	swarmTaskIDLabel      = "com.docker.swarm.task.id"
+	swarmTaskNameLabel    = "com.docker.swarm.task.name"
+	swarmServiceNameLabel = "com.docker.swarm.service.name"
+)
+
+// labels added to the metrics of task containers with --labels.swarm
+var swarmLabelNames = []string{"service_name", "task_slot"}
+
+// normalizeSwarmName strips the task ID from the name of a swarm task container,
+// myservice.3.abc123def456 becomes myservice.3. Other names are returned unchanged.
+func normalizeSwarmName(name string, labels map[string]string) string {
+	taskID := labels[swarmTaskIDLabel]
+	if taskID == "" {
+		return name
+	}
+	return strings.TrimSuffix(name, "."+taskID)
+}
+
+// swarmLabelValues returns the service name and task slot of a swarm task
+// container, the task name is <service>.<slot>.<task id>. The slot of tasks of
+// global services is the node ID. Other containers get empty values.
+func swarmLabelValues(labels map[string]string) []string {
+	service := labels[swarmServiceNameLabel]
+	if service == "" {
+		return []string{"", ""}
+	}
+
+	slot := strings.TrimPrefix(labels[swarmTaskNameLabel], service+".")
+	slot = strings.TrimSuffix(slot, "."+labels[swarmTaskIDLabel])
+	if slot == labels[swarmTaskNameLabel] {
+		slot = ""
+	}
+	return []string{service, slot}
+}
+
+// dedupeSwarmTasks keeps only the newest container of every normalized name,
+// e.g. the replacement task of a slot while the old one is still around.
+func dedupeSwarmTasks(containers []types.Container) []types.Container {
+	newest := map[string]int{}
+	for i, container := range containers {
+		name := normalizeSwarmName(containerName(container), container.Labels)
+		if j, ok := newest[name]; !ok || containers[j].Created < container.Created {
+			newest[name] = i
+		}
+	}
+
+	if len(newest) == len(containers) {
+		return containers
+	}
+
+	deduped := make([]types.Container, 0, len(newest))
+	for i, container := range containers {
+		name := normalizeSwarmName(containerName(container), container.Labels)
+		if newest[name] == i {
+			deduped = append(deduped, container)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestNormalizeSwarmName(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{
+			name:   "myservice.3.abc123def456",
+			labels: map[string]string{swarmTaskIDLabel: "abc123def456"},
+			want:   "myservice.3",
+		},
+		{
+			name:   "myservice.k2vx1mv0yb9nd3q3xl2z3ltm8.abc123def456",
+			labels: map[string]string{swarmTaskIDLabel: "abc123def456"},
+			want:   "myservice.k2vx1mv0yb9nd3q3xl2z3ltm8",
+		},
+		{
+			name: "web",
+			want: "web",
+		},
+		{
+			name:   "project-web-1",
+			labels: map[string]string{"com.docker.compose.service": "web"},
+			want:   "project-web-1",
+		},
+		{
+			// renamed task container, the ID is not a suffix anymore
+			name:   "renamed",
+			labels: map[string]string{swarmTaskIDLabel: "abc123def456"},
+			want:   "renamed",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeSwarmName(tt.name, tt.labels); got != tt.want {
+			t.Errorf("normalizeSwarmName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSwarmLabelValues(t *testing.T) {
+	tests := []struct {
+		labels      map[string]string
+		wantService string
+		wantSlot    string
+	}{
+		{
+			labels: map[string]string{
+				swarmServiceNameLabel: "myservice",
+				swarmTaskNameLabel:    "myservice.3.abc123def456",
+				swarmTaskIDLabel:      "abc123def456",
+			},
+			wantService: "myservice",
+			wantSlot:    "3",
+		},
+		{
+			labels: map[string]string{"com.docker.compose.service": "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		values := swarmLabelValues(tt.labels)
+		if values[0] != tt.wantService || values[1] != tt.wantSlot {
+			t.Errorf("swarmLabelValues(%v) = %q, want [%q %q]", tt.labels, values, tt.wantService, tt.wantSlot)
+		}
+	}
+}
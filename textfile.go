@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// runTextfileWriter gathers the metrics every interval and writes them in the
+// text format to path, e.g. for the textfile collector of the node exporter.
+func runTextfileWriter(ctx context.Context, gatherer prometheus.Gatherer, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := writeTextfile(gatherer, path); err != nil {
+				log.Error("can't write metrics textfile: ", err)
+			}
+		}
+	}
+}
+
+// writeTextfile replaces path atomically, readers never see a partial file.
+// The temporary file doesn't end in .prom, so the node exporter ignores it.
+func writeTextfile(gatherer prometheus.Gatherer, path string) error {
+	mfs, err := gatherer.Gather()
+	if err != nil {
+		// whatever was gathered is still written
+		log.Error("can't gather metrics for the textfile: ", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToText(tmp, mf); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
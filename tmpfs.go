@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	units "github.com/docker/go-units"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	containerTmpfsLimitBytesDesc = newContainerDesc(
+		"container_tmpfs_limit_bytes",
+		"Configured size limit of a tmpfs mount in bytes",
+		"destination",
+	)
+	containerTmpfsUsageBytesDesc = newContainerDesc(
+		"container_tmpfs_usage_bytes",
+		"Used bytes of a tmpfs mount",
+		"destination",
+	)
+)
+
+func (c *DockerCollector) tmpfsMetrics(ch chan<- prometheus.Metric, info *types.ContainerJSON, l *containerLabels) {
+	limits := map[string]int64{}
+
+	// --tmpfs /dest:size=64m,mode=1777
+	for destination, options := range info.HostConfig.Tmpfs {
+		limits[destination] = 0
+		for _, option := range strings.Split(options, ",") {
+			if size, ok := strings.CutPrefix(option, "size="); ok {
+				if bytes, err := units.RAMInBytes(size); err == nil {
+					limits[destination] = bytes
+				}
+			}
+		}
+	}
+
+	// --mount type=tmpfs,destination=/dest,tmpfs-size=67108864
+	for _, m := range info.HostConfig.Mounts {
+		if m.Type != mount.TypeTmpfs {
+			continue
+		}
+		limits[m.Target] = 0
+		if m.TmpfsOptions != nil {
+			limits[m.Target] = m.TmpfsOptions.SizeBytes
+		}
+	}
+
+	for destination, limit := range limits {
+		// tmpfs without size option is only limited by host memory
+		if limit > 0 {
+			ch <- prometheus.MustNewConstMetric(l.desc(containerTmpfsLimitBytesDesc), prometheus.GaugeValue, float64(limit), l.valuesWith(destination)...)
+		}
+
+		if info.State == nil || info.State.Pid == 0 {
+			continue
+		}
+
+		// the tmpfs lives in the container's mount namespace, reachable through its init process
+		path := filepath.Join(fmt.Sprintf("/proc/%d/root", info.State.Pid), destination)
+		used, err := fsUsedBytes(path)
+		if err != nil {
+			log.Debugf("can't stat tmpfs %s of container %s: %v", destination, l.name(), err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(l.desc(containerTmpfsUsageBytesDesc), prometheus.GaugeValue, float64(used), l.valuesWith(destination)...)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// build metadata, set with -ldflags "-X main.version=... -X main.revision=... -X main.buildDate=..."
+var (
+	version   = "unknown"
+	revision  = "unknown"
+	buildDate = "unknown"
+)
+
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "dex version %s\n", version)
+	fmt.Fprintf(w, "  revision:   %s\n", revision)
+	fmt.Fprintf(w, "  build date: %s\n", buildDate)
+	fmt.Fprintf(w, "  go version: %s\n", runtime.Version())
+}
+
+// newBuildInfo creates the build_info metric, always 1 with the build metadata as labels.
+func newBuildInfo(namespace string) prometheus.Collector {
+	buildInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build metadata of dex, always 1",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	buildInfo.Set(1)
+	return buildInfo
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+// maximum time /healthz waits for the docker daemon
+const healthzTimeout = 2 * time.Second
+
+// loggingResponseWriter records status and size of a response.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// logRequests logs every request handled by next, at info level if enabled
+// with --web.log-requests, at debug level otherwise.
+func logRequests(next http.Handler, enabled bool) http.Handler {
+	level := log.DebugLevel
+	if enabled {
+		level = log.InfoLevel
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !log.IsLevelEnabled(level) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+
+		log.WithFields(log.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"remote":   r.RemoteAddr,
+			"status":   lw.status,
+			"bytes":    lw.bytes,
+			"duration": time.Since(start),
+		}).Log(level, "http request")
+	})
+}
+
+// metricsHandler serves the metrics of reg. Unless softFail is set, it responds
+// with 503 if no docker daemon was reachable (dex_up is 0), so the target is
+// marked down instead of silently serving no container metrics. With several
+// daemons the metrics of the reachable ones are served. Clients accepting
+// OpenMetrics get it with units and created timestamps.
+func metricsHandler(reg *prometheus.Registry, softFail bool, namespace string) http.Handler {
+	opts := promhttp.HandlerOpts{
+		Registry:          reg,
+		EnableOpenMetrics: true,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mfs, err := reg.Gather()
+		if !softFail && isDown(mfs, prometheus.BuildFQName(namespace, "", "up")) {
+			http.Error(w, "docker daemon not reachable", http.StatusServiceUnavailable)
+			return
+		}
+		setUnits(mfs)
+
+		if err == nil && wantsOpenMetrics(r) {
+			writeOpenMetrics(w, r, mfs)
+			return
+		}
+
+		// promhttp handles gather errors and the other formats
+		gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return mfs, err
+		})
+		promhttp.HandlerFor(gatherer, opts).ServeHTTP(w, r)
+	})
+}
+
+// isDown reports whether the up metric (dex_up) is present and 0 for all
+// docker daemons.
+func isDown(mfs []*dto.MetricFamily, up string) bool {
+	for _, mf := range mfs {
+		if mf.GetName() != up {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() != 0 {
+				return false
+			}
+		}
+		return len(mf.GetMetric()) > 0
+	}
+	return false
+}
+
+// pinger is implemented by dockerClient.
+type pinger interface {
+	Ping(ctx context.Context) (types.Ping, error)
+}
+
+// healthzHandler responds with 200 if any of the docker daemons answers a
+// ping and with 503 otherwise.
+func healthzHandler(clients []pinger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthzTimeout)
+		defer cancel()
+
+		reachable := make(chan bool, len(clients))
+		for _, cli := range clients {
+			go func(cli pinger) {
+				_, err := cli.Ping(ctx)
+				if err != nil {
+					log.Debug("health check failed: ", err)
+				}
+				reachable <- err == nil
+			}(cli)
+		}
+
+		for range clients {
+			if <-reachable {
+				fmt.Fprintln(w, "ok")
+				return
+			}
+		}
+		http.Error(w, "docker daemon not reachable", http.StatusServiceUnavailable)
+	})
+}
+
+// landingPage serves a page linking to the metrics at /, all other paths not
+// handled otherwise are not found.
+func landingPage(metricsPath string) http.Handler {
+	page := fmt.Sprintf(`<html>
+<head><title>dex</title></head>
+<body>
+<h1>dex - Docker EXporter</h1>
+<p><a href="%s">Metrics</a></p>
+<p><a href="/healthz">Health</a></p>
+</body>
+</html>
+`, html.EscapeString(metricsPath))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	})
+}
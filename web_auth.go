@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// compared against for unknown users, so they take as long as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dex"), bcrypt.DefaultCost)
+
+// loadUsers reads a htpasswd file with bcrypt hashes (htpasswd -B).
+func loadUsers(file string) (map[string][]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	users := map[string][]byte{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		user, hash, found := strings.Cut(text, ":")
+		if !found || user == "" {
+			return nil, fmt.Errorf("%s line %d: expected user:hash", file, line)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s line %d: password of %s is not a bcrypt hash", file, line, user)
+		}
+		users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users", file)
+	}
+
+	return users, nil
+}
+
+// basicAuth only passes requests with valid credentials to next, all others
+// are answered with 401.
+func basicAuth(next http.Handler, users map[string][]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+
+		hash, known := users[user]
+		if !known {
+			hash = dummyHash
+		}
+		match := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+		if !ok || !known || !match {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dex"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certificate holds the TLS certificate of the server, it's reloaded on SIGHUP.
+type certificate struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.Mutex
+	cert *tls.Certificate
+}
+
+func newCertificate(certFile, keyFile string) (*certificate, error) {
+	c := &certificate{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load (re-)reads the certificate, on error the current one is kept.
+func (c *certificate) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.mu.Unlock()
+
+	log.Infof("loaded TLS certificate from %s", c.certFile)
+	return nil
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cert, nil
+}
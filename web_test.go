@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// fakePinger is a docker daemon answering pings with err.
+type fakePinger struct {
+	err error
+}
+
+func (p fakePinger) Ping(context.Context) (types.Ping, error) {
+	return types.Ping{}, p.err
+}
+
+func TestHealthzHandler(t *testing.T) {
+	reachable := fakePinger{}
+	unreachable := fakePinger{err: errors.New("connection refused")}
+
+	tests := []struct {
+		name    string
+		clients []pinger
+		want    int
+	}{
+		{
+			name:    "reachable",
+			clients: []pinger{reachable},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "unreachable",
+			clients: []pinger{unreachable},
+			want:    http.StatusServiceUnavailable,
+		},
+		{
+			name:    "one of several reachable",
+			clients: []pinger{unreachable, reachable},
+			want:    http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			healthzHandler(tt.clients).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
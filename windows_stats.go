@@ -0,0 +1,71 @@
+package main
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	memoryCommitBytesDesc = newContainerDesc(
+		"memory_commit_bytes",
+		"Committed memory in bytes (Windows only)",
+	)
+	memoryCommitPeakBytesDesc = newContainerDesc(
+		"memory_commit_peak_bytes",
+		"Peak committed memory in bytes (Windows only)",
+	)
+)
+
+// isWindowsStats reports whether the stats come from a Windows container. Only
+// Windows reports the number of processors, the CPU times are in 100ns units
+// there and there are no cgroup memory, blkio and pids stats.
+func isWindowsStats(containerStats *types.StatsJSON) bool {
+	return containerStats.NumProcs > 0
+}
+
+// cpuSeconds returns the total CPU time of the container in seconds.
+func cpuSeconds(containerStats *types.StatsJSON) float64 {
+	if isWindowsStats(containerStats) {
+		return float64(containerStats.CPUStats.CPUUsage.TotalUsage) / 1e7
+	}
+	return float64(containerStats.CPUStats.CPUUsage.TotalUsage) / 1e9
+}
+
+// windowsCPUMetrics reports the CPU usage of a Windows container, there's no
+// throttling and per-core usage.
+func (c *DockerCollector) windowsCPUMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, limitCPUs float64, l *containerLabels) {
+	if utilization, ok := windowsCPUUtilization(containerStats); ok {
+		ch <- prometheus.MustNewConstMetric(l.desc(cpuUtilizationPercentDesc), prometheus.GaugeValue, utilization, l.values...)
+		if limitCPUs > 0 {
+			ch <- prometheus.MustNewConstMetric(l.desc(cpuUtilizationOfLimitPercentDesc), prometheus.GaugeValue, utilization/limitCPUs, l.values...)
+		}
+	}
+
+	ch <- l.counter(cpuUtilizationSecondsTotalDesc, cpuSeconds(containerStats), l.values...)
+}
+
+// windowsCPUUtilization is like docker stats on Windows, but 100% per core as
+// on Linux instead of 100% for all cores: the used 100ns intervals of the wall
+// time between both reads.
+func windowsCPUUtilization(containerStats *types.StatsJSON) (float64, bool) {
+	intervals := containerStats.Read.Sub(containerStats.PreRead).Nanoseconds() / 100
+	totalUsage := containerStats.CPUStats.CPUUsage.TotalUsage
+	preUsage := containerStats.PreCPUStats.CPUUsage.TotalUsage
+	if containerStats.PreRead.IsZero() || intervals <= 0 || totalUsage < preUsage {
+		return 0, false
+	}
+
+	return float64(totalUsage-preUsage) / float64(intervals) * 100.0, true
+}
+
+// windowsMemoryMetrics reports the private working set as usage, like docker
+// stats does on Windows.
+func (c *DockerCollector) windowsMemoryMetrics(ch chan<- prometheus.Metric, containerStats *types.StatsJSON, l *containerLabels) {
+	memory := containerStats.MemoryStats
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryUsageBytesDesc), prometheus.GaugeValue, float64(memory.PrivateWorkingSet), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryWorkingSetBytesDesc), prometheus.GaugeValue, float64(memory.PrivateWorkingSet), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryCommitBytesDesc), prometheus.GaugeValue, float64(memory.Commit), l.values...)
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryCommitPeakBytesDesc), prometheus.GaugeValue, float64(memory.CommitPeak), l.values...)
+	// no cgroups on Windows
+	ch <- prometheus.MustNewConstMetric(l.desc(memoryCgroupVersionDesc), prometheus.GaugeValue, 0, l.values...)
+}
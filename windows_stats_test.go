@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestWindowsStats(t *testing.T) {
+	stats, err := os.ReadFile("testdata/stats_windows.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctr := types.Container{ID: "c3", Names: []string{"/iis"}, State: "running"}
+	cli := &fakeDocker{
+		containers: []types.Container{ctr},
+		stats:      map[string]string{ctr.ID: string(stats)},
+	}
+	c := newDockerCollector(testConfig(), cli)
+
+	m := process(t, c, ctr)
+
+	want := map[string]float64{
+		"dex_memory_usage_bytes":            59146240,
+		"dex_memory_working_set_bytes":      59146240,
+		"dex_memory_commit_bytes":           85463040,
+		"dex_memory_commit_peak_bytes":      101904384,
+		"dex_memory_cgroup_version":         0,
+		"dex_cpu_utilization_percent":       50,
+		"dex_cpu_utilization_seconds_total": 21.25,
+		"dex_network_rx_bytes_total":        1452365,
+		"dex_network_tx_bytes_total":        253478,
+		"dex_network_rx_dropped_total":      12,
+		"dex_container_scrape_success":      1,
+		"dex_container_running":             1,
+	}
+	for name, want := range want {
+		got, ok := m.get(name, "container_name", "iis")
+		if !ok {
+			t.Errorf("%s missing", name)
+		} else if got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	// no cgroups on Windows
+	for _, name := range []string{"dex_memory_cache_bytes", "dex_memory_total_bytes", "dex_pids_current", "dex_block_io_read_bytes_total"} {
+		if _, ok := m.get(name); ok {
+			t.Errorf("%s reported, want none", name)
+		}
+	}
+}
+
+func TestHostAggregateMemoryUsage(t *testing.T) {
+	windows, err := os.ReadFile("testdata/stats_windows.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		stats string
+		want  float64
+	}{
+		{
+			name:  "cgroup v1",
+			stats: statsCgroupV1,
+			want:  700,
+		},
+		{
+			name:  "cache larger than usage",
+			stats: `{"memory_stats": {"usage": 100, "stats": {"cache": 300, "total_inactive_file": 200}}}`,
+			want:  0,
+		},
+		{
+			name:  "windows",
+			stats: string(windows),
+			want:  59146240,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats types.StatsJSON
+			if err := json.Unmarshal([]byte(tt.stats), &stats); err != nil {
+				t.Fatal(err)
+			}
+
+			agg := newHostAggregate(nil)
+			agg.addStats(&stats, 0)
+			if agg.memoryUsage != tt.want {
+				t.Errorf("memory usage = %v, want %v", agg.memoryUsage, tt.want)
+			}
+		})
+	}
+}
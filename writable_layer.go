@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// maximum directory depth walked below a writable layer
+const writableLayerMaxDepth = 64
+
+var errWalkBudget = errors.New("walk budget exceeded")
+
+// WritableLayerCollector reports the disk usage of the writable (upper) layer of
+// running containers. Walking the layers is expensive, so it is done in the
+// background on a slow interval and the last result is served on scrape.
+type WritableLayerCollector struct {
+	cli      *dockerClient
+	desc     *prometheus.Desc
+	interval time.Duration
+	budget   time.Duration
+
+	mu    sync.Mutex
+	sizes map[string]float64
+}
+
+func newWritableLayerCollector(cli *dockerClient, namespace string, interval, budget time.Duration) *WritableLayerCollector {
+	c := &WritableLayerCollector{
+		cli: cli,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "container_writable_layer_bytes"),
+			"Disk usage of the container's writable layer in bytes",
+			labelCname,
+			nil,
+		),
+		interval: interval,
+		budget:   budget,
+		sizes:    map[string]float64{},
+	}
+
+	go c.run()
+
+	return c
+}
+
+func (c *WritableLayerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *WritableLayerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for cName, size := range c.sizes {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, size, cName)
+	}
+}
+
+func (c *WritableLayerCollector) run() {
+	for {
+		c.refresh()
+		time.Sleep(c.interval)
+	}
+}
+
+func (c *WritableLayerCollector) refresh() {
+	containers, err := c.cli.ContainerList(context.Background(), container.ListOptions{})
+	if err != nil {
+		log.Error("can't list containers: ", err)
+		return
+	}
+
+	sizes := map[string]float64{}
+	for _, container := range containers {
+		cName := containerName(container)
+
+		info, err := c.cli.ContainerInspect(context.Background(), container.ID)
+		if err != nil {
+			log.Error("can't inspect container: ", err)
+			continue
+		}
+
+		upperDir := info.GraphDriver.Data["UpperDir"]
+		if upperDir == "" {
+			log.Debugf("no writable layer path for container %s (storage driver %s)", cName, info.GraphDriver.Name)
+			continue
+		}
+
+		size, err := walkSize(upperDir, c.budget)
+		if errors.Is(err, errWalkBudget) {
+			log.Warnf("writable layer of container %s not fully walked within %v, reporting partial size", cName, c.budget)
+		} else if err != nil {
+			log.Error("can't walk writable layer: ", err)
+			continue
+		}
+		sizes[cName] = float64(size)
+	}
+
+	c.mu.Lock()
+	c.sizes = sizes
+	c.mu.Unlock()
+}
+
+// walkSize sums up the size of all regular files below root. The walk stops
+// with errWalkBudget when it takes longer than budget, the size walked so far
+// is returned in that case.
+func walkSize(root string, budget time.Duration) (int64, error) {
+	var size int64
+	deadline := time.Now().Add(budget)
+	rootDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files vanish while the container is running
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errWalkBudget
+		}
+		if d.IsDir() {
+			if strings.Count(path, string(filepath.Separator))-rootDepth > writableLayerMaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}